@@ -1,6 +1,6 @@
 package cryptopals
 
-import "crypto/aes"
+import "fmt"
 
 /*
 Implement CBC mode
@@ -17,57 +17,85 @@ The file here is intelligible (somewhat) when CBC decrypted against "YELLOW SUBM
 */
 
 func AESInCBCModeEncrypt(pt, key, iv []byte) []byte {
-	cipher, err := aes.NewCipher(key)
+	out, err := AESInCBCModeEncryptChecked(pt, key, iv)
 	if err != nil {
 		panic(err)
 	}
+	return out
+}
+
+// AESInCBCModeEncryptChecked is AESInCBCModeEncrypt, but returns an error
+// instead of panicking on a bad key, IV or unaligned plaintext.
+func AESInCBCModeEncryptChecked(pt, key, iv []byte) ([]byte, error) {
+	cipher, err := newAESCipher(key)
+	if err != nil {
+		return nil, err
+	}
 
 	bs := cipher.BlockSize() //should always return 16
-	dst := make([]byte, bs)
-
-	var out []byte
-
-	blocks := BreakIntoBlocks(pt, bs)
-
-	for i := 0; i < len(blocks); i++ {
-		block := blocks[i]
-		if i > 0 {
-			prevBlock := out[bs*(i-1) : bs*i]
-			block = XOr(block, prevBlock)
-		} else {
-			block = XOr(block, iv)
-		}
-		cipher.Encrypt(dst, block)
-		out = append(out, dst...)
+	if len(iv) != bs {
+		return nil, fmt.Errorf("%w: %d bytes", ErrInvalidIVSize, len(iv))
 	}
-	return out
+	if len(pt)%bs != 0 {
+		return nil, fmt.Errorf("%w: plaintext is %d bytes", ErrNotBlockAligned, len(pt))
+	}
+
+	out := make([]byte, len(pt))
+	NewCBCEncrypter(cipher, iv).CryptBlocks(out, pt)
+	return out, nil
+}
+
+// AESInCBCModeEncryptWithPadding pads pt with padding and encrypts it in
+// CBC mode.
+func AESInCBCModeEncryptWithPadding(pt, key, iv []byte, padding Padding) ([]byte, error) {
+	cipher, err := newAESCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(iv) != cipher.BlockSize() {
+		return nil, fmt.Errorf("%w: %d bytes", ErrInvalidIVSize, len(iv))
+	}
+	return EncryptWithPadding(NewCBCEncrypter(cipher, iv), padding, pt)
 }
 
 func AESInCBCModeDecrypt(ct, key, iv []byte) []byte {
-	cipher, err := aes.NewCipher(key)
+	out, err := AESInCBCModeDecryptChecked(ct, key, iv)
 	if err != nil {
 		panic(err)
 	}
+	return out
+}
 
-	bs := cipher.BlockSize()
-	dst := make([]byte, bs)
-
-	var out []byte
+// AESInCBCModeDecryptChecked is AESInCBCModeDecrypt, but returns an error
+// instead of panicking on a bad key, IV or unaligned ciphertext.
+func AESInCBCModeDecryptChecked(ct, key, iv []byte) ([]byte, error) {
+	cipher, err := newAESCipher(key)
+	if err != nil {
+		return nil, err
+	}
 
-	blocks := BreakIntoBlocks(ct, bs)
+	bs := cipher.BlockSize()
+	if len(iv) != bs {
+		return nil, fmt.Errorf("%w: %d bytes", ErrInvalidIVSize, len(iv))
+	}
+	if len(ct)%bs != 0 {
+		return nil, fmt.Errorf("%w: ciphertext is %d bytes", ErrNotBlockAligned, len(ct))
+	}
 
-	for i := 0; i < len(blocks); i++ {
-		var pt []byte
-		block := blocks[i]
-		cipher.Decrypt(dst, block)
+	out := make([]byte, len(ct))
+	NewCBCDecrypter(cipher, iv).CryptBlocks(out, ct)
+	return out, nil
+}
 
-		if i > 0 {
-			pt = XOr(dst, blocks[i-1])
-		} else {
-			pt = XOr(dst, iv)
-		}
-		out = append(out, pt...)
+// AESInCBCModeDecryptWithPadding decrypts ct in CBC mode and removes the
+// padding.
+func AESInCBCModeDecryptWithPadding(ct, key, iv []byte, padding Padding) ([]byte, error) {
+	cipher, err := newAESCipher(key)
+	if err != nil {
+		return nil, err
 	}
-
-	return out
+	if len(iv) != cipher.BlockSize() {
+		return nil, fmt.Errorf("%w: %d bytes", ErrInvalidIVSize, len(iv))
+	}
+	return DecryptWithPadding(NewCBCDecrypter(cipher, iv), padding, ct)
 }
@@ -1,7 +1,7 @@
 package cryptopals
 
 import (
-	"crypto/aes"
+	"fmt"
 	"log"
 )
 
@@ -27,55 +27,82 @@ Detect the block cipher mode the function is using each time. You should end up
 */
 
 func AESInECBModeEncrypt(ct, key []byte) []byte {
-	cipher, err := aes.NewCipher(key)
+	out, err := AESInECBModeEncryptChecked(ct, key)
 	if err != nil {
 		panic(err)
 	}
+	return out
+}
+
+// AESInECBModeEncryptChecked is AESInECBModeEncrypt, but returns an error
+// instead of panicking on a bad key or unaligned plaintext.
+func AESInECBModeEncryptChecked(ct, key []byte) ([]byte, error) {
+	cipher, err := newAESCipher(key)
+	if err != nil {
+		return nil, err
+	}
 	bs := cipher.BlockSize()
 	if len(ct)%bs != 0 {
-		panic("Ciphertext length needs to be a multiple of the blocksize")
+		return nil, fmt.Errorf("%w: plaintext is %d bytes", ErrNotBlockAligned, len(ct))
 	}
-	dst := make([]byte, bs)
-	var out []byte
+	out := make([]byte, len(ct))
+	NewECBEncrypter(cipher).CryptBlocks(out, ct)
+	return out, nil
+}
 
-	for i := 0; i < len(ct); i += bs {
-		cipher.Encrypt(dst, ct[i:i+bs])
-		out = append(out, dst[:]...)
+// AESInECBModeEncryptWithPadding pads pt with padding and encrypts it in
+// ECB mode.
+func AESInECBModeEncryptWithPadding(pt, key []byte, padding Padding) ([]byte, error) {
+	cipher, err := newAESCipher(key)
+	if err != nil {
+		return nil, err
 	}
-
-	return out
+	return EncryptWithPadding(NewECBEncrypter(cipher), padding, pt)
 }
 
 func CBCOrECBEncrypt(pt []byte) []byte {
-	doECB := GetRandomInt(2) == 1
+	ct, mode := CBCOrECBEncryptWithMode(pt)
+	log.Printf("Encrypting with %v mode...", mode)
+	return ct
+}
 
-	prefixByteLength := GetRandomInt(5) + 5
-	postfixByteLength := GetRandomInt(5) + 5
+// CBCOrECBEncryptWithMode is CBCOrECBEncrypt, but also returns the mode it
+// picked.
+func CBCOrECBEncryptWithMode(pt []byte) ([]byte, CipherMode) {
+	oracle, mode := CBCOrECBOracle()
+	return oracle(pt), mode
+}
 
-	prefixBytes := Key(prefixByteLength)
-	postfixBytes := Key(postfixByteLength)
+/*
+CBCOrECBOracle makes the same random choices as CBCOrECBEncrypt - mode,
+key, and 5-10 bytes before and after the input - but only once, and returns
+an oracle that keeps using them along with the mode it chose. CBC still
+gets a new random IV every time.
+*/
+func CBCOrECBOracle() (func([]byte) []byte, CipherMode) {
+	doECB := GetRandomInt(2) == 1
+	prefixBytes := Key(GetRandomInt(5) + 5)
+	postfixBytes := Key(GetRandomInt(5) + 5)
 	key := Key(16)
-
-	pt = append(prefixBytes, pt...)
-	pt = append(pt, postfixBytes...)
-
-	pt = Pad(pt, 16)
+	mode := ModeCBC
 	if doECB {
-		log.Println("Encrypting with ECB mode...")
-		return AESInECBModeEncrypt(pt, key[:])
-	} else {
-		log.Println("Encrypting with CBC mode...")
-		iv := Key(16)
-		return AESInCBCModeEncrypt(pt, key[:], iv[:])
+		mode = ModeECB
 	}
+	return func(input []byte) []byte {
+		pt := append(append(append([]byte{}, prefixBytes...), input...), postfixBytes...)
+		pt = Pad(pt, 16)
+		if doECB {
+			return AESInECBModeEncrypt(pt, key)
+		}
+		return AESInCBCModeEncrypt(pt, key, Key(16))
+	}, mode
 }
 
-func DetectAESOrECB() {
-	pt := make([]byte, 100, 100)
-	ct := CBCOrECBEncrypt(pt)
-	if AESInECBModeOracle(ct) {
-		log.Println("ECB mode detected")
-	} else {
-		log.Println("CBC mode detected")
-	}
+// DetectAESOrECB points DetectMode at a CBCOrECBOracle, logs what it found
+// and returns whether it picked the right mode.
+func DetectAESOrECB() bool {
+	oracle, mode := CBCOrECBOracle()
+	report := DetectMode(oracle)
+	log.Printf("Mode detected: %v", report)
+	return report.Mode == mode
 }
@@ -52,77 +52,11 @@ func ECBWithUnknownSuffix() func([]byte) []byte {
 	}
 }
 
-//finds the block size of a block cipher. returns the
-//block size and the original padding for the last block of the ciphertext
-func findBlockSizeAndPadding(encrypter func([]byte) []byte) (int, int) {
-	var payload []byte
-	startingLength := len(encrypter(payload))
-	currentLength := startingLength
-	for startingLength == currentLength {
-		payload = append(payload, byte('A'))
-		currentLength = len(encrypter(payload))
-	}
-	blockSize := currentLength - startingLength
-	paddingLength := len(payload)
-	return blockSize, paddingLength
-}
-
-//admittedly, this function is a bit messy...
+// AttackECBSuffix recovers the unknown string from ECBWithUnknownSuffix.
 func AttackECBSuffix() []byte {
-	encrypter := ECBWithUnknownSuffix()
-	//Discover the block size of the cipher.
-
-	blockSize, paddingLength := findBlockSizeAndPadding(encrypter)
-	//Detect that the function is using ECB
-	payload := make([]byte, blockSize*2, blockSize*2)
-	ct := encrypter(payload)
-	if !AESInECBModeOracle(ct) {
-		panic("ECB mode not detected")
-	}
-
-	//Recover the plaintext
-	payload = make([]byte, 16, 16)
-	pt := []byte{}
-	ctLength := len(encrypter([]byte("")))
-
-	//for blockStart := 0; blockStart < ctLength; blockStart+=blockSize {
-	for blockStart := 0; blockStart < ctLength; blockStart += blockSize {
-		blockEnd := blockStart + blockSize
-		isLastBlock := blockEnd == ctLength
-		ptBlock := []byte("")
-
-		/* get plaintext for each block */
-		for i := blockStart; i < blockEnd; i++ {
-			payload = payload[1:]
-
-			/* deal with dynamic padding values for the last block */
-			if isLastBlock && len(ptBlock)+paddingLength > 16 {
-				paddingVal := len(ptBlock) + paddingLength - 15
-				for i := len(ptBlock) - 1; i > len(ptBlock)-paddingVal; i-- {
-					ptBlock[i] = byte(paddingVal)
-				}
-			}
-
-			/* build block map */
-			blockMap := make(map[string]string)
-			for i := 0; i < 256; i++ {
-
-				fullBlock := append(payload, ptBlock...)
-				fullBlock = append(fullBlock, byte(i))
-
-				encryptedBlock := string(encrypter(fullBlock)[0:blockSize])
-				blockMap[encryptedBlock] = string(fullBlock)
-			}
-
-			payloadEncrypted := encrypter(payload)[blockStart:blockEnd]
-			foundPlaintext := blockMap[string(payloadEncrypted)]
-			lastByte := foundPlaintext[15]
-			ptBlock = append(ptBlock, lastByte)
-		}
-
-		payload = ptBlock
-		pt = append(pt, ptBlock...)
+	report, err := BreakECBSuffix(ECBWithUnknownSuffix())
+	if err != nil {
+		panic(err)
 	}
-
-	return pt
+	return report.Suffix
 }
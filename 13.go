@@ -1,7 +1,5 @@
 package cryptopals
 
-import "strings"
-
 /*
 ECB cut-and-paste
 
@@ -45,38 +43,46 @@ Now, two more easy functions. Generate a random AES key, then:
 Using only the user input to profile_for() (as an oracle to generate "valid" ciphertexts) and the ciphertexts themselves, make a role=admin profile.
 */
 
+// profileTemplate is the layout of every cookie profileFor makes.
+var profileTemplate = Cookie{{"email", ""}, {"uid", "10"}, {"role", "user"}}
+
+// profileFor encodes a user profile. The email address is escaped, so it
+// can't add fields of its own.
 func profileFor(email string) string {
-	email = strings.Replace(email, "&", "", -1)
-	email = strings.Replace(email, "=", "", -1)
-	return "email=" + email + "&uid=10&role=user"
+	profile := append(Cookie{}, profileTemplate...)
+	profile[0].Value = email
+	return profile.Encode()
 }
 
+// initProfileEncryption returns an oracle that encrypts the profile for an
+// email address, and a function that decrypts a profile and reports
+// whether it has role=admin.
 func initProfileEncryption() (func([]byte) []byte, func([]byte) bool) {
 	key := Key(16)
-	encrypt := func(profile []byte) []byte {
-		profile = Pad(profile, 16)
+	encrypt := func(email []byte) []byte {
+		profile := Pad([]byte(profileFor(string(email))), 16)
 		return AESInECBModeEncrypt(profile, key)
 	}
-	isAdmin := func(profile []byte) bool {
-		decodedProfile := AESInECBModeDecrypt(profile, key)
-		pairs := strings.Split(string(decodedProfile), "&")
-		for _, p := range pairs {
-			k := strings.Split(p, "=")
-			if k[0] == "role" && k[1] == "admin" {
-				return true
-			}
+	isAdmin := func(ct []byte) bool {
+		pt, err := AESInECBModeDecryptWithPadding(ct, key, PKCS7Padding{})
+		if err != nil {
+			return false
 		}
-		return false
+		profile, err := ParseCookie(string(pt))
+		if err != nil {
+			return false
+		}
+		role, _ := profile.Get("role")
+		return role == "admin"
 	}
 	return encrypt, isAdmin
 }
 
 func createAdminProfile() bool {
 	encrypt, isAdmin := initProfileEncryption()
-	p := profileFor("aaaaaaaaaaaaa")
-	a := encrypt([]byte(p))
-	firstTwoBlocks := a[:32]
-	adminBlock := encrypt([]byte(profileFor("aaaaaaaaaaadmin")))[16:32]
-	payload := append(firstTwoBlocks, adminBlock...)
-	return isAdmin(payload)
+	forged, err := ECBCutAndPaste(encrypt, profileTemplate, "email", "role", "admin")
+	if err != nil {
+		return false
+	}
+	return isAdmin(forged)
 }
@@ -1,6 +1,6 @@
 package cryptopals
 
-import "errors"
+import "crypto/subtle"
 
 /*
 
@@ -29,19 +29,46 @@ Crypto nerds know where we're going with this. Bear with us.
 
 //StripPadding calculates and removes PKCS#7 padding,
 //and either returning the input without padding, or returning
-//an error if there is no valid padding.
+//a *PaddingError if there is no valid padding.
 func StripPadding(b []byte) ([]byte, error) {
+	if len(b) == 0 {
+		return nil, &PaddingError{"PKCS#7", "input is empty"}
+	}
+	n, err := trailingLength("PKCS#7", b, len(b))
+	if err != nil {
+		return nil, err
+	}
+	return stripPKCS7(b, n)
+}
+
+/*
+StripPaddingConstantTime is StripPadding without the timing leak. StripPadding
+stops at the first byte that doesn't match, so how long it takes depends on
+where the padding goes wrong; this version looks at every byte of the last
+block whatever the padding says, and folds the checks together with
+crypto/subtle instead of branching on them. It also checks that the input is
+a whole number of blocks, which StripPadding can't since it isn't told the
+block size.
+
+The error doesn't say what was wrong, since that would leak too. Whether the
+padding was valid at all is still leaked by the return value: that's the
+padding oracle, and no amount of constant-time code fixes it.
+*/
+func StripPaddingConstantTime(b []byte, blockSize int) ([]byte, error) {
+	if err := checkUnpadInput("PKCS#7", b, blockSize); err != nil {
+		return nil, err
+	}
 	length := len(b)
-	lastByte := b[length-1]
-	paddingLen := int(lastByte)
-	if paddingLen > length || paddingLen == 0 {
-		return nil, errors.New("invalid padding!")
+	paddingLen := int(b[length-1])
+	good := subtle.ConstantTimeLessOrEq(1, paddingLen) & subtle.ConstantTimeLessOrEq(paddingLen, blockSize)
+	for i := 0; i < blockSize; i++ {
+		inPadding := subtle.ConstantTimeLessOrEq(i+1, paddingLen)
+		matches := subtle.ConstantTimeByteEq(b[length-1-i], byte(paddingLen))
+		//outside the padding, any byte is fine
+		good &= matches | (inPadding ^ 1)
 	}
-	for i := 0; i < paddingLen; i++ {
-		index := length - i - 1
-		if b[index] != lastByte {
-			return nil, errors.New("invalid padding!")
-		}
+	if good != 1 {
+		return nil, &PaddingError{"PKCS#7", "bad padding"}
 	}
 	return b[:length-paddingLen], nil
 }
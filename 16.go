@@ -78,12 +78,14 @@ func initCommentEncryption() (func([]byte) []byte, func([]byte) bool) {
 
 func createBitflippedAdmin() bool {
 	encryptComment, checkAdmin := initCommentEncryption()
-	payload := []byte(":admin<true")
-	ct := encryptComment(payload)
-	//flip some bits - changes ':' and '<' characters to ';' and '='
-	//in the plaintext
-	ct[16] ^= 1
-	ct[22] ^= 1
-
+	prefix := "comment1=cooking%20MCs;userdata="
+	plan, err := PlanBitflip(ModeCBC, len(prefix), 16, []byte(";admin=true;"))
+	if err != nil {
+		return false
+	}
+	ct, err := plan.Apply(encryptComment(plan.Input))
+	if err != nil {
+		return false
+	}
 	return checkAdmin(ct)
 }
@@ -69,49 +69,18 @@ func CBCServerMockup() (ct, iv []byte, checkPadding func([]byte) bool) {
 	return
 }
 
+// CBCPaddingOracle breaks the mockup server's ciphertext with
+// BreakPaddingOracle, and returns the plaintext without its padding.
 func CBCPaddingOracle() []byte {
-	var out []byte
-	ct, iv, decrypt := CBCServerMockup()
-	blocks := BreakIntoBlocks(ct, 16)
-	iv2 := [][]byte{iv}
-	blocks = append(iv2, blocks...)
-	for b := 1; b < len(blocks); b++ {
-		target := blocks[b]
-		prev := make([]byte, 16)
-		copy(prev, blocks[b-1])
-
-		//find initial padding for block
-		initialPadding := 0
-
-		if decrypt(append(prev, target...)) {
-			prevCopy := make([]byte, 16)
-			copy(prevCopy, prev)
-			i := -1
-			for decrypt(append(prevCopy, target...)) {
-				i++
-				prevCopy[i] ^= 0xFF
-			}
-			initialPadding = 16 - i
-		}
-
-		for padding := initialPadding; padding <= 15; padding++ {
-			targetChar := 16 - padding - 1
-			newPaddingVal := padding + 1
-			for i := targetChar + 1; i < 16; i++ {
-				prev[i] ^= byte(padding) ^ byte(newPaddingVal)
-			}
-
-			i := 0
-			for i = 0; i < 256; i++ {
-				prev[targetChar] = byte(i)
-				if decrypt(append(prev, target...)) {
-					break
-				}
-
-			}
-		}
-		v := XOr(prev, blocks[b-1])
-		out = append(out, SingleByteXOrCipher(v, 16)...)
+	ct, iv, checkPadding := CBCServerMockup()
+	//the server always decrypts with its own IV, so send ours as an extra
+	//block in front
+	oracle := func(forgedIV, ct []byte) bool {
+		return checkPadding(append(append([]byte{}, forgedIV...), ct...))
+	}
+	pt, _, err := BreakPaddingOracle(oracle, 16, iv, ct)
+	if err != nil {
+		panic(err)
 	}
-	return out
+	return pt
 }
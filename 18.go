@@ -1,6 +1,6 @@
 package cryptopals
 
-import "encoding/binary"
+import "fmt"
 
 /*
 Implement CTR, the stream cipher mode
@@ -43,31 +43,28 @@ Decrypt the string at the top of this function, then use your CTR function to en
 */
 
 func CTR_Cipher(text, key, nonce []byte) []byte {
-	var counter uint64
-	bs := make([]byte, 8)
-	var output []byte
-
-	for i := 0; i < len(text); i += 16 {
-		binary.LittleEndian.PutUint64(bs, counter)
-
-		blockStart := i
-		blockEnd := i + 16
-		blockLength := 16
-
-		if blockEnd >= len(text) {
-			blockEnd = len(text)
-			blockLength = blockEnd - blockStart
-		}
-
-		block := text[blockStart:blockEnd]
-
-		//use AESInECBMode function. Encrypting one block in ECB is the same as
-		//calling the AES cipher directly, but by calling AESInECBMode we don't
-		//have to write the extra code :)
-		stream := AESInECBModeEncrypt(append(nonce, bs...), key)
-		newBlock := XOr(block, stream[:blockLength])
-		output = append(output, newBlock...)
-		counter++
+	out, err := CTR_CipherChecked(text, key, nonce)
+	if err != nil {
+		panic(err)
 	}
-	return output
+	return out
+}
+
+// CTR_CipherChecked is CTR_Cipher, but returns an error instead of panicking
+// on a bad key or a nonce that isn't 8 bytes long.
+func CTR_CipherChecked(text, key, nonce []byte) ([]byte, error) {
+	if len(nonce) != 8 {
+		return nil, fmt.Errorf("%w: %d bytes, expected 8", ErrInvalidNonceSize, len(nonce))
+	}
+	block, err := newAESCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	ctr, err := NewCTRWithNonce(block, nonce, 0, CTRLayoutChallenge18)
+	if err != nil {
+		return nil, err
+	}
+	output := make([]byte, len(text))
+	ctr.XORKeyStream(output, text)
+	return output, nil
 }
@@ -1,5 +1,7 @@
 package cryptopals
 
+import "fmt"
+
 /*
 Fixed XOR
 
@@ -20,13 +22,22 @@ If your function works properly, then when you feed it the string:
 
 //XOr xor's two equal length byte slices, and panics if the input slices aren't the same length
 func XOr(b1, b2 []byte) []byte {
+	out, err := XOrChecked(b1, b2)
+	if err != nil {
+		panic(err)
+	}
+	return out
+}
+
+// XOrChecked is XOr, but returns ErrLengthMismatch instead of panicking.
+func XOrChecked(b1, b2 []byte) ([]byte, error) {
 	if len(b1) != len(b2) {
-		panic("buffers are not the same length")
+		return nil, fmt.Errorf("%w: %d and %d bytes", ErrLengthMismatch, len(b1), len(b2))
 	}
 
 	out := make([]byte, len(b1))
 	for i := 0; i < len(b1); i++ {
 		out[i] = b1[i] ^ b2[i]
 	}
-	return out
+	return out, nil
 }
@@ -1,9 +1,6 @@
 package cryptopals
 
-import (
-	"encoding/base64"
-	"log"
-)
+import "encoding/base64"
 
 /*
 Break fixed-nonce CTR statistically
@@ -19,30 +16,73 @@ To exploit this: take your collection of ciphertexts and truncate them to a comm
 Solve the resulting concatenation of ciphertexts as if for repeating- key XOR, with a key size of the length of the ciphertext you XOR'd.
 */
 
-func BreakCTRStatistically() {
+// BreakCTRStatistically encrypts the lines of input/20.txt under a fixed
+// nonce, then breaks them with BreakFixedNonceCTR and returns the plaintexts.
+func BreakCTRStatistically() ([][]byte, error) {
+	return BreakCTRStatisticallyWithScorer(LetterFrequencyScorer{})
+}
+
+func BreakCTRStatisticallyWithScorer(scorer Scorer) ([][]byte, error) {
 	nonce := Key(8)
 	key := Key(16)
 
-	lines, _ := ReadFileByLine("input/19.txt")
-	minLength := -1
+	lines, err := ReadFileByLine("input/20.txt")
+	if err != nil {
+		return nil, err
+	}
+	var ciphertexts [][]byte
 	for _, line := range lines {
-		l, _ := base64.StdEncoding.DecodeString(line)
+		l, err := base64.StdEncoding.DecodeString(line)
+		if err != nil {
+			return nil, err
+		}
+		ciphertexts = append(ciphertexts, CTR_Cipher(l, key, nonce))
+	}
+	_, _, plaintexts := BreakFixedNonceCTR(ciphertexts, scorer)
+	return plaintexts, nil
+}
 
-		if len(l) < minLength || minLength == -1 {
-			minLength = len(l)
+/*
+BreakFixedNonceCTR recovers the keystream shared by a set of ciphertexts,
+out to the length of the longest one. Instead of truncating everything to
+the shortest ciphertext, every keystream byte is solved as single-byte XOR
+over however many ciphertexts reach that far.
+
+It returns the keystream, a confidence for each keystream byte and the
+plaintexts. The confidence is the relative gap between the best and the
+runner-up score for that byte, scaled down when only a few ciphertexts
+reach it; 0 means the column couldn't tell its top two candidates apart.
+*/
+func BreakFixedNonceCTR(cts [][]byte, scorer Scorer) ([]byte, []float64, [][]byte) {
+	longest := 0
+	for _, ct := range cts {
+		if len(ct) > longest {
+			longest = len(ct)
 		}
 	}
 
-	var ciphertext []byte
-	for _, line := range lines {
-		l, _ := base64.StdEncoding.DecodeString(line)
+	keystream := make([]byte, longest)
+	confidence := make([]float64, longest)
+	for col := 0; col < longest; col++ {
+		var column []byte
+		for _, ct := range cts {
+			if col < len(ct) {
+				column = append(column, ct[col])
+			}
+		}
+		candidates := SingleByteXOrCandidates(column, scorer, 2)
+		keystream[col] = candidates[0].Key[0]
 
-		l = CTR_Cipher(l, key, nonce)
-		ciphertext = append(ciphertext, l[:minLength]...)
+		best, second := candidates[0].Score, candidates[1].Score
+		if second > 0 {
+			samples := float64(len(column)) / float64(len(cts))
+			confidence[col] = (second - best) / second * samples
+		}
 	}
-	key = BreakRepeatingKeyXOrCipher(ciphertext)
-	blocks := BreakIntoBlocks(RepeatingKeyXOrCipher(ciphertext, key), minLength)
-	for _, b := range blocks {
-		log.Println(string(b))
+
+	var plaintexts [][]byte
+	for _, ct := range cts {
+		plaintexts = append(plaintexts, XOr(ct, keystream[:len(ct)]))
 	}
+	return keystream, confidence, plaintexts
 }
@@ -1,5 +1,10 @@
 package cryptopals
 
+import (
+	"math/bits"
+	"math/rand"
+)
+
 /*
 Implement the MT19937 Mersenne Twister RNG
 
@@ -9,23 +14,77 @@ If you're writing in Python, Ruby, or (gah) PHP, your language is probably alrea
 */
 
 // based on pseudocode from https://en.wikipedia.org/w/index.php?title=Mersenne_Twister&oldid=408201780
+//
+// The zero value is seeded with 5489 on first use, as the reference
+// implementation (mt19937ar.c) does.
 type MersenneTwister struct {
 	state []uint32
 	index int
 }
 
-// Initialize the generator from a seed
-func (mt *MersenneTwister) init(seed uint32) {
+// MT19937DefaultSeed is the seed the reference implementation uses when it
+// isn't given one.
+const MT19937DefaultSeed = 5489
+
+// NewMT19937 returns a generator seeded with seed, like init_genrand.
+func NewMT19937(seed uint32) *MersenneTwister {
+	mt := &MersenneTwister{}
+	mt.Seed(seed)
+	return mt
+}
+
+// Seed initializes the generator from a seed, like init_genrand.
+func (mt *MersenneTwister) Seed(seed uint32) {
 	mt.state = make([]uint32, 624)
 	mt.state[0] = seed
 	for i := 1; i < 624; i++ { // loop over each other element
 		mt.state[i] = (1812433253 * (mt.state[i-1] ^ (mt.state[i-1] >> 30))) + uint32(i)
 	}
+	mt.index = 0
 }
 
-// Extract a tempered pseudorandom number based on the mt.index-th value,
-// calling generateNumbers() every 624 numbers
-func (mt *MersenneTwister) ExtractNumber() uint32 {
+/*
+SeedByArray initializes the generator from an array of any length, like
+init_by_array. This is how Python seeds its random module: random.seed(n)
+uses the 32-bit words of abs(n), least significant first. An empty key is
+treated as {0}.
+*/
+func (mt *MersenneTwister) SeedByArray(key []uint32) {
+	if len(key) == 0 {
+		key = []uint32{0}
+	}
+	mt.Seed(19650218)
+	i, j := 1, 0
+	k := len(key)
+	if k < 624 {
+		k = 624
+	}
+	for ; k > 0; k-- {
+		mt.state[i] = (mt.state[i] ^ ((mt.state[i-1] ^ (mt.state[i-1] >> 30)) * 1664525)) + key[j%len(key)] + uint32(j%len(key))
+		i++
+		j++
+		if i >= 624 {
+			mt.state[0] = mt.state[623]
+			i = 1
+		}
+	}
+	for k = 623; k > 0; k-- {
+		mt.state[i] = (mt.state[i] ^ ((mt.state[i-1] ^ (mt.state[i-1] >> 30)) * 1566083941)) - uint32(i)
+		i++
+		if i >= 624 {
+			mt.state[0] = mt.state[623]
+			i = 1
+		}
+	}
+	mt.state[0] = 0x80000000 //MSB is 1, assuring a non-zero initial array
+}
+
+// Uint32 returns the next tempered output, calling generateNumbers() every
+// 624 numbers.
+func (mt *MersenneTwister) Uint32() uint32 {
+	if mt.state == nil {
+		mt.Seed(MT19937DefaultSeed)
+	}
 	if mt.index == 0 {
 		mt.generateNumbers()
 	}
@@ -40,6 +99,104 @@ func (mt *MersenneTwister) ExtractNumber() uint32 {
 	return y
 }
 
+// Extract a tempered pseudorandom number; the same as Uint32
+func (mt *MersenneTwister) ExtractNumber() uint32 {
+	return mt.Uint32()
+}
+
+// Uint64 returns two outputs as one number, the first as the low 32 bits,
+// as Python's random.getrandbits(64) does.
+func (mt *MersenneTwister) Uint64() uint64 {
+	lo := uint64(mt.Uint32())
+	return uint64(mt.Uint32())<<32 | lo
+}
+
+// Float64 returns a number in [0, 1) with 53 bits of precision from two
+// outputs, like genrand_res53 and Python's random.random().
+func (mt *MersenneTwister) Float64() float64 {
+	a, b := mt.Uint32()>>5, mt.Uint32()>>6
+	return (float64(a)*67108864 + float64(b)) / 9007199254740992
+}
+
+// bits returns a k-bit number, 0 < k <= 64, like Python's
+// random.getrandbits(k).
+func (mt *MersenneTwister) bits(k int) uint64 {
+	if k <= 32 {
+		return uint64(mt.Uint32() >> (32 - k))
+	}
+	lo := uint64(mt.Uint32())
+	return uint64(mt.Uint32()>>(64-k))<<32 | lo
+}
+
+/*
+Intn returns a number in [0, n) by drawing numbers as wide as n and
+rejecting those that are too big, as Python's random.randrange(n) does. It
+panics if n <= 0.
+*/
+func (mt *MersenneTwister) Intn(n int) int {
+	if n <= 0 {
+		panic("invalid argument to Intn")
+	}
+	k := bits.Len64(uint64(n))
+	for {
+		if r := mt.bits(k); r < uint64(n) {
+			return int(r)
+		}
+	}
+}
+
+/*
+MT19937Source adapts a MersenneTwister to math/rand's Source64, so
+rand.New can be used on top of it. The numbers math/rand derives from a
+source aren't the ones Python or PHP would; use the MersenneTwister
+methods to match those.
+*/
+type MT19937Source struct {
+	MT *MersenneTwister
+}
+
+var _ rand.Source64 = (*MT19937Source)(nil)
+
+// NewMT19937Source returns a source seeded like Python's random.seed(seed).
+// The zero value works too, and is seeded with MT19937DefaultSeed.
+func NewMT19937Source(seed int64) *MT19937Source {
+	s := &MT19937Source{}
+	s.Seed(seed)
+	return s
+}
+
+// mt returns the generator, making one if there isn't one yet.
+func (s *MT19937Source) mt() *MersenneTwister {
+	if s.MT == nil {
+		s.MT = &MersenneTwister{}
+	}
+	return s.MT
+}
+
+// Seed seeds the generator with the 32-bit words of abs(seed), like
+// Python's random.seed.
+func (s *MT19937Source) Seed(seed int64) {
+	n := uint64(seed)
+	if seed < 0 {
+		n = -n
+	}
+	key := []uint32{uint32(n)}
+	if n>>32 != 0 {
+		key = append(key, uint32(n>>32))
+	}
+	s.mt().SeedByArray(key)
+}
+
+// Uint64 is MersenneTwister.Uint64.
+func (s *MT19937Source) Uint64() uint64 {
+	return s.mt().Uint64()
+}
+
+// Int63 returns the top 63 bits of Uint64.
+func (s *MT19937Source) Int63() int64 {
+	return int64(s.mt().Uint64() >> 1)
+}
+
 // Generate an array of 624 untempered numbers
 func (mt *MersenneTwister) generateNumbers() {
 	for i := 0; i < 624; i++ {
@@ -27,7 +27,7 @@ func WaitThenGenerateNum() uint32 {
 	wait1 := GetRandomInt(960) + 40
 	wait2 := GetRandomInt(960) + 40
 	time.Sleep(time.Duration(wait1) * time.Second)
-	mt.init(uint32(time.Now().Unix()))
+	mt.Seed(uint32(time.Now().Unix()))
 	log.Println("seed done")
 	time.Sleep(time.Duration(wait2) * time.Second)
 	return mt.ExtractNumber()
@@ -40,7 +40,7 @@ func CrackSeed(firstNum uint32) {
 		seed := uint32(t - int64(i))
 		log.Println(seed)
 
-		mt.init(seed)
+		mt.Seed(seed)
 		if mt.ExtractNumber() == firstNum {
 			log.Printf("found that seed!!!!! seed %v %v", seed, i)
 			return
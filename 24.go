@@ -27,8 +27,7 @@ Write a function to check if any given password token is actually the product of
 func MT19937StreamCipher(text []byte, key uint16) []byte {
 	var output []byte
 
-	mt := MersenneTwister{}
-	mt.init(uint32(key))
+	mt := NewMT19937(uint32(key))
 	var keyStreamBuffer []byte
 	for _, t := range text {
 		if len(keyStreamBuffer) == 0 {
@@ -1,6 +1,9 @@
 package cryptopals
 
-import "log"
+import (
+	"bytes"
+	"fmt"
+)
 
 /*
 Break "random access read/write" AES CTR
@@ -14,37 +17,99 @@ Imagine the "edit" function was exposed to attackers by means of an API call tha
 Recover the original plaintext.
 */
 
+/*
+CTRRandomAccessReadWrite encrypts plaintext under a random key and nonce, and
+returns the ciphertext and the edit function. Edits are made through an
+unauthenticated EncryptedFile, so only the blocks they touch are encrypted
+again; an edit past the end extends the ciphertext. The ciphertext passed
+in isn't changed.
+*/
 func CTRRandomAccessReadWrite(plaintext []byte) ([]byte, func([]byte, int, []byte) []byte) {
 	nonce := Key(8)
 	key := Key(16)
 	ciphertext := CTR_Cipher(plaintext, key, nonce)
 
 	updatePlaintext := func(ct []byte, offset int, newtext []byte) []byte {
-		pt := CTR_Cipher(ct, key, nonce)
-		for i, b := range newtext {
-			index := i + offset
-			if index > len(pt) {
-				break
-			}
-			pt[index] = b
+		storage := &memoryStorage{append([]byte{}, ct...)}
+		f, err := NewEncryptedFile(storage, int64(len(ct)), key, nonce)
+		if err != nil {
+			panic(err)
 		}
-		return CTR_Cipher(pt, key, nonce)
+		if _, err := f.WriteAt(newtext, int64(offset)); err != nil {
+			panic(err)
+		}
+		return storage.data
 	}
 	return ciphertext, updatePlaintext
 }
 
+// EditOracle is challenge 25's edit function: it returns the ciphertext
+// with the plaintext at offset replaced by newtext.
+type EditOracle func(ct []byte, offset int, newtext []byte) []byte
+
 func BreakCTRRandomAccess(ciphertext []byte, updatePlaintext func([]byte, int, []byte) []byte) []byte {
-	var out []byte
-	for index := range ciphertext {
-		log.Println(index)
-		for i := 0; i < 256; i++ {
-			payload := byte(i)
-			newCiphertext := updatePlaintext(ciphertext, index, []byte{payload})
-			if newCiphertext[index] == ciphertext[index] {
-				out = append(out, payload)
-				break
+	pt, _, err := BreakCTREditOracle(ciphertext, updatePlaintext)
+	if err != nil {
+		panic(err)
+	}
+	return pt
+}
+
+/*
+BreakCTREditOracle recovers the plaintext of ct with one edit, or two if
+the plaintext is all zeros already. Writing zeros over the whole plaintext
+makes the edited ciphertext the keystream itself, and XORing that with ct
+gives the plaintext. It returns the plaintext and the number of edits made.
+*/
+func BreakCTREditOracle(ct []byte, edit EditOracle) ([]byte, int, error) {
+	return BreakCTREditOracleChunked(ct, edit, len(ct))
+}
+
+/*
+BreakCTREditOracleChunked is BreakCTREditOracle for an oracle that won't
+edit more than maxEdit bytes at once. It zeroes the plaintext one chunk at a
+time, always editing the original ciphertext: ceil(len(ct)/maxEdit) edits,
+plus one for every chunk whose plaintext is all zeros, so up to
+2*ceil(len(ct)/maxEdit).
+
+An edit that leaves the chunk as it was either hit plaintext that was
+already zeros or was ignored, so the chunk is edited again to 0xff bytes,
+which has to change it. If that doesn't either, the oracle isn't doing the
+edits it's asked for, and it returns an error wrapping ErrUnexpectedOracle.
+*/
+func BreakCTREditOracleChunked(ct []byte, edit EditOracle, maxEdit int) ([]byte, int, error) {
+	if len(ct) == 0 {
+		return []byte{}, 0, nil
+	}
+	if maxEdit < 1 {
+		return nil, 0, fmt.Errorf("%w: edits of %d bytes", ErrInvalidLength, maxEdit)
+	}
+	pt := make([]byte, len(ct))
+	zeros := make([]byte, maxEdit)
+	ones := bytes.Repeat([]byte{0xff}, maxEdit)
+	queries := 0
+	for off := 0; off < len(ct); off += maxEdit {
+		end := off + maxEdit
+		if end > len(ct) {
+			end = len(ct)
+		}
+		newtext := zeros[:end-off]
+		edited := edit(ct, off, newtext)
+		queries++
+		if len(edited) >= end && bytes.Equal(edited[off:end], ct[off:end]) {
+			newtext = ones[:end-off]
+			edited = edit(ct, off, newtext)
+			queries++
+			if len(edited) >= end && bytes.Equal(edited[off:end], ct[off:end]) {
+				return nil, queries, fmt.Errorf("%w: edit at %d didn't change the ciphertext", ErrUnexpectedOracle, off)
 			}
 		}
+		if len(edited) < end {
+			return nil, queries, fmt.Errorf("%w: edit at %d returned %d bytes of ciphertext", ErrUnexpectedOracle, off, len(edited))
+		}
+		for i := off; i < end; i++ {
+			pt[i] = ct[i] ^ edited[i] ^ newtext[i-off]
+		}
 	}
-	return out
+	return pt, queries, nil
 }
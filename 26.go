@@ -54,12 +54,14 @@ func initCommentEncryptionCTR() (func([]byte) []byte, func([]byte) bool) {
 
 func createBitflippedAdminCTR() bool {
 	encryptComment, checkAdmin := initCommentEncryptionCTR()
-	payload := []byte(":admin<true")
-	ct := encryptComment(payload)
-	//flip some bits - changes ':' and '<' characters to ';' and '='
-	//in the plaintext
-	ct[32] ^= 1
-	ct[38] ^= 1
-
+	//find the prefix length instead of counting it
+	plan, err := PlanBitflipFromOracle(encryptComment, ModeStream, []byte(";admin=true;"))
+	if err != nil {
+		return false
+	}
+	ct, err := plan.Apply(encryptComment(plan.Input))
+	if err != nil {
+		return false
+	}
 	return checkAdmin(ct)
 }
@@ -1,9 +1,10 @@
 package cryptopals
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"log"
-	"strconv"
 	"strings"
 )
 
@@ -33,12 +34,26 @@ As the attacker, recovering the plaintext from the error, extract the key:
 P'_1 XOR P'_3
 */
 
+// NonASCIIError is returned by a receiver that finds high-ASCII bytes in a
+// decrypted message. Like a lot of real error messages, it includes the
+// plaintext it rejected. It wraps ErrNonASCII.
+type NonASCIIError struct {
+	Plaintext []byte
+}
+
+func (e *NonASCIIError) Error() string {
+	return fmt.Sprintf("non-ASCII characters found: %q", e.Plaintext)
+}
+
+func (e *NonASCIIError) Unwrap() error {
+	return ErrNonASCII
+}
+
 func initCommentEncryptionWithMatchingKeyAndIv() (func([]byte) []byte, func([]byte) (bool, error)) {
 	adminString := ";admin=true;"
 
 	key := Key(16)
 	iv := key
-	log.Println("key", key)
 	encryptComment := func(comment []byte) []byte {
 		prependText := []byte("comment1=cooking%20MCs;userdata=")
 		appendText := []byte(";comment2=%20like%20a%20pound%20of%20bacon")
@@ -65,10 +80,13 @@ func initCommentEncryptionWithMatchingKeyAndIv() (func([]byte) []byte, func([]by
 		return AESInCBCModeEncrypt(comment, key, iv)
 	}
 	decryptAndCheckAdmin := func(ct []byte) (bool, error) {
-		pt := AESInCBCModeDecrypt(ct, key, iv)
+		pt, err := AESInCBCModeDecryptChecked(ct, key, iv)
+		if err != nil {
+			return false, err
+		}
 		for _, b := range pt {
-			if b&0x8 > 0 {
-				return false, fmt.Errorf("non-ASCII characters found: %v", pt)
+			if b&0x80 != 0 {
+				return false, &NonASCIIError{pt}
 			}
 		}
 		if strings.Contains(string(pt), adminString) {
@@ -79,27 +97,63 @@ func initCommentEncryptionWithMatchingKeyAndIv() (func([]byte) []byte, func([]by
 	return encryptComment, decryptAndCheckAdmin
 }
 
+/*
+RecoverKeyUsedAsIV recovers the key from a service that encrypts with
+AES-128-CBC using the key as the IV, given a way to get messages encrypted
+and a receiver that returns a *NonASCIIError (or an error wrapping one)
+when it rejects a message.
+
+It encrypts a message of at least three blocks, sends C_1, 0, C_1 in place
+of its first three blocks, and takes the key from the plaintext that comes
+back in the error: P'_1 XOR P'_3 = IV = key. The key is then checked by
+decrypting the original message with it, which has to give back the
+filler that was sent. Under any other key it decrypts to garbage.
+*/
+func RecoverKeyUsedAsIV(encrypt func([]byte) []byte, decrypt func([]byte) error) ([]byte, error) {
+	const bs = 16
+	chosen := fill(ecbFiller, 3*bs)
+	ct := encrypt(chosen)
+	if len(ct) < 3*bs || len(ct)%bs != 0 {
+		return nil, fmt.Errorf("%w: %d byte ciphertext", ErrUnexpectedOracle, len(ct))
+	}
+
+	//C_1, 0, C_1, then the rest unchanged so the padding is still valid
+	modified := make([]byte, 0, len(ct))
+	modified = append(modified, ct[:bs]...)
+	modified = append(modified, make([]byte, bs)...)
+	modified = append(modified, ct[:bs]...)
+	modified = append(modified, ct[3*bs:]...)
+
+	var rejected *NonASCIIError
+	if err := decrypt(modified); !errors.As(err, &rejected) {
+		return nil, fmt.Errorf("%w: receiver didn't return the plaintext: %v", ErrKeyRecoveryFailed, err)
+	}
+	pt := rejected.Plaintext
+	if len(pt) < 3*bs {
+		return nil, fmt.Errorf("%w: receiver returned %d bytes of plaintext", ErrKeyRecoveryFailed, len(pt))
+	}
+	key := XOr(pt[:bs], pt[2*bs:3*bs])
+
+	original, err := AESInCBCModeDecryptChecked(ct, key, key)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Contains(original, chosen) {
+		return nil, fmt.Errorf("%w: recovered key doesn't decrypt the message", ErrKeyRecoveryFailed)
+	}
+	return key, nil
+}
+
 func findCBCKey() []byte {
 	encryptComment, checkAdmin := initCommentEncryptionWithMatchingKeyAndIv()
-	ct := encryptComment([]byte{})
-	firstBlock := ct[:16]
-	newCt := append(firstBlock, make([]byte, 16)...)
-	newCt = append(newCt, firstBlock...)
-
-	_, err := checkAdmin(ct)
+	decrypt := func(ct []byte) error {
+		_, err := checkAdmin(ct)
+		return err
+	}
+	key, err := RecoverKeyUsedAsIV(encryptComment, decrypt)
 	if err != nil {
-		e := err.Error()
-		start := strings.Index(e, "[")
-		end := strings.Index(e, "]")
-		e = e[start+1 : end]
-		stringBytes := strings.Split(e, " ")
-		var pt []byte
-		for _, s := range stringBytes {
-			i, _ := strconv.Atoi(s)
-			pt = append(pt, byte(i))
-		}
-		return XOr(pt[0:16], pt[32:48])
+		log.Println("Attack didn't work:", err)
+		return nil
 	}
-	log.Fatal("Attack didn't work")
-	return []byte{}
+	return key
 }
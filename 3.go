@@ -2,7 +2,7 @@ package cryptopals
 
 import (
 	"math"
-	"unicode"
+	"sort"
 )
 
 /*
@@ -37,62 +37,60 @@ the key, plaintext, and the difference between the average letter
 distribution for English and the plaintext.
 */
 func BreakSingleByteXOrCipher(ct []byte) (byte, []byte, float64) {
-	letterFrequency := map[byte]float64{
-		' ': 0.182884,
-		'E': 0.102666,
-		'T': 0.075169,
-		'A': 0.065321,
-		'O': 0.061595,
-		'N': 0.057120,
-		'I': 0.056684,
-		'S': 0.053170,
-		'R': 0.049879,
-		'H': 0.049785,
-		'L': 0.033175,
-		'D': 0.032829,
-		'U': 0.022757,
-		'C': 0.022336,
-		'M': 0.020265,
-		'F': 0.019830,
-		'W': 0.017038,
-		'G': 0.016249,
-		'P': 0.015043,
-		'Y': 0.014276,
-		'B': 0.012588,
-		'V': 0.007961,
-		'K': 0.005609,
-		'X': 0.001409,
-		'J': 0.000975,
-		'Q': 0.000836,
-		'Z': 0.000512,
-	}
+	return BreakSingleByteXOrCipherWithScorer(ct, LetterFrequencyScorer{})
+}
+
+/*
+BreakSingleByteXOrCipherWithScorer tries every single-byte key and returns
+the key, plaintext and score of the candidate that scorer rates best.
+*/
+func BreakSingleByteXOrCipherWithScorer(ct []byte, scorer Scorer) (byte, []byte, float64) {
 	lowest := math.MaxFloat64
 	plaintext := []byte{}
 	var outKey byte
 
 	for key := 0; key < 256; key++ {
 		pt := SingleByteXOrCipher(ct, byte(key))
-		newFreq := make(map[byte]float64)
-		otherCharacters := 0
-
-		for _, letter := range pt {
-			letter := byte(unicode.ToUpper(rune(letter)))
-			if letterFrequency[letter] > 0 {
-				newFreq[letter] = 1 / float64(len(ct))
-			} else {
-				otherCharacters += 1
-			}
-		}
-		var difference float64
-		for k, _ := range letterFrequency {
-			difference += math.Abs(letterFrequency[k] - newFreq[k])
-		}
-		difference += float64(otherCharacters) / float64(len(ct))
-		if difference < lowest {
-			lowest = difference
+		score := scorer.Score(pt)
+		if score < lowest {
+			lowest = score
 			plaintext = pt
 			outKey = byte(key)
 		}
 	}
 	return outKey, plaintext, lowest
 }
+
+// XOrCandidate is one possible decryption of an XOR ciphertext.
+type XOrCandidate struct {
+	Key       []byte
+	Plaintext []byte
+	Score     float64
+}
+
+/*
+SingleByteXOrCandidates scores the decryption under every single-byte key
+and returns the best n of them, sorted from best to worst. Ties keep the
+lower key first.
+*/
+func SingleByteXOrCandidates(ct []byte, scorer Scorer, n int) []XOrCandidate {
+	if n < 1 {
+		return nil
+	}
+	candidates := make([]XOrCandidate, 256)
+	for key := 0; key < 256; key++ {
+		pt := SingleByteXOrCipher(ct, byte(key))
+		candidates[key] = XOrCandidate{
+			Key:       []byte{byte(key)},
+			Plaintext: pt,
+			Score:     scorer.Score(pt),
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Score < candidates[j].Score
+	})
+	if n < len(candidates) {
+		candidates = candidates[:n]
+	}
+	return candidates
+}
@@ -1,5 +1,7 @@
 package cryptopals
 
+import "math"
+
 /*
 Detect single-character XOR
 
@@ -20,3 +22,22 @@ func SingleByteXOrOracle(ct []byte) bool {
 	}
 	return false
 }
+
+/*
+DetectSingleByteXOr breaks every ciphertext in cts with scorer and returns
+the index, key and plaintext of the one whose best decryption scores best.
+The index is -1 if cts is empty.
+*/
+func DetectSingleByteXOr(cts [][]byte, scorer Scorer) (int, byte, []byte) {
+	bestIndex := -1
+	bestScore := math.MaxFloat64
+	var bestKey byte
+	var bestPt []byte
+	for i, ct := range cts {
+		key, pt, score := BreakSingleByteXOrCipherWithScorer(ct, scorer)
+		if score < bestScore {
+			bestIndex, bestScore, bestKey, bestPt = i, score, key, pt
+		}
+	}
+	return bestIndex, bestKey, bestPt
+}
@@ -1,6 +1,9 @@
 package cryptopals
 
-import "math"
+import (
+	"container/heap"
+	"math"
+)
 
 /*
 Break repeating-key XOR
@@ -43,49 +46,234 @@ func HammingDistance(b1, b2 []byte) int {
 	return hd
 }
 
+// FindBestXOrKeyLength returns the most likely key length between 2 and 40
+// according to HammingKeySizes, or 1 if the ciphertext is too short to tell.
 func FindBestXOrKeyLength(ct []byte) int {
-	smallestHd := math.MaxFloat64
-	var keySize int
-	for i := 2; i < 41; i++ {
-		hd := float64(HammingDistance(ct[:i*4], ct[i*4:i*8])) / float64(i)
-		if hd < smallestHd {
-			smallestHd = hd
-			keySize = i
-		}
+	guesses := HammingKeySizes(ct, 2, 40)
+	if len(guesses) == 0 {
+		return 1
 	}
-	return keySize
+	return guesses[0].KeySize
 }
 
 func BreakRepeatingKeyXOrCipher(ct []byte) []byte {
-	keySize := FindBestXOrKeyLength(ct)
-	var ctBlocks [][]byte
-	//break the ciphertext into blocks of KEYSIZE length
-	//the last block may be shorter if ct % keySize != 0
-	for i := 0; i < len(ct)-keySize; i += keySize {
-		endIndex := i + keySize
-		if endIndex > len(ct) {
-			endIndex = len(ct)
-		}
-		ctBlocks = append(ctBlocks, ct[i:endIndex])
-	}
-	//Now transpose the blocks: make a block that is the first byte of every
-	//block, and a block that is the second byte of every block, and so on.
-	var singleByteXOrBlocks [][]byte
-	for i := 0; i < keySize; i++ {
-		var newBlock []byte
-		for _, block := range ctBlocks {
-			if i < len(block) {
-				newBlock = append(newBlock, block[i])
+	return BreakRepeatingKeyXOrCipherWithScorer(ct, LetterFrequencyScorer{})
+}
+
+// BreakRepeatingKeyXOrCipherWithScorer is BreakRepeatingKeyXOrCipher, but
+// each transposed block is solved using scorer. The three most likely key
+// lengths between 2 and 40 are tried, along with their divisors, since on
+// short ciphertexts HammingKeySizes often ranks a multiple of the key length
+// above the length itself. It only considers lengths that fit in the
+// ciphertext at least twice.
+func BreakRepeatingKeyXOrCipherWithScorer(ct []byte, scorer Scorer) []byte {
+	guesses := HammingKeySizes(ct, 2, 40)
+	if len(guesses) > 3 {
+		guesses = guesses[:3]
+	}
+	tried := make(map[int]bool)
+	for _, g := range guesses {
+		tried[g.KeySize] = true
+	}
+	for _, g := range guesses {
+		for d := 2; d < g.KeySize; d++ {
+			if g.KeySize%d == 0 && !tried[d] {
+				tried[d] = true
+				guesses = append(guesses, KeySizeGuess{KeySize: d})
 			}
 		}
-		singleByteXOrBlocks = append(singleByteXOrBlocks, newBlock)
+	}
+	return BreakRepeatingKeyXOrCipherWithKeySizes(ct, guesses, scorer)
+}
+
+/*
+BreakRepeatingKeyXOrCipherWithKeySizes breaks the ciphertext once for every
+guessed key length and returns the key for the length that heldOutScore
+rates best. Earlier guesses win ties. A key that is just a shorter key
+repeated (which is what guessing a multiple of the real length gives you)
+is cut down to the shorter key. With no guesses, a single-byte key is
+assumed.
+*/
+func BreakRepeatingKeyXOrCipherWithKeySizes(ct []byte, guesses []KeySizeGuess, scorer Scorer) []byte {
+	if len(guesses) == 0 {
+		guesses = []KeySizeGuess{{KeySize: 1, Confidence: 1}}
+	}
+	best := 0
+	bestScore := math.MaxFloat64
+	for _, guess := range guesses {
+		if guess.KeySize < 1 {
+			continue
+		}
+		score := heldOutScore(ct, guess.KeySize, scorer)
+		if best == 0 || score < bestScore {
+			best, bestScore = guess.KeySize, score
+		}
+	}
+	if best == 0 {
+		best = 1
 	}
 	//Solve each block as if it was single-character XOR
 	var key []byte
-	for _, block := range singleByteXOrBlocks {
-		newChar, _, _ := BreakSingleByteXOrCipher(block)
+	for _, block := range transposeBlocks(ct, best) {
+		newChar, _, _ := BreakSingleByteXOrCipherWithScorer(block, scorer)
 		key = append(key, newChar)
 	}
+	return shortestPeriod(key)
+}
+
+// heldOutMaxRows is how many rows of the transposed ciphertext heldOutScore
+// looks at.
+const heldOutMaxRows = 256
+
+/*
+heldOutScore rates a key length without letting it overfit. Scoring the
+whole decryption favours long keys: with only a few bytes per column, any
+column can be made to look like English. Instead, each column is split into
+its even and odd rows, a key byte is found from each half, and every byte is
+decrypted with the key byte found from the other half. With the right
+length both halves find the same key; with a wrong one, what fits one half
+is noise on the other. Only the first heldOutMaxRows rows are used, which is
+plenty to tell the lengths apart.
+*/
+func heldOutScore(ct []byte, keySize int, scorer Scorer) float64 {
+	if len(ct) > heldOutMaxRows*keySize {
+		ct = ct[:heldOutMaxRows*keySize]
+	}
+	even, odd := make([]byte, keySize), make([]byte, keySize)
+	for c, block := range transposeBlocks(ct, keySize) {
+		var halves [2][]byte
+		for row, b := range block {
+			halves[row%2] = append(halves[row%2], b)
+		}
+		even[c], _, _ = BreakSingleByteXOrCipherWithScorer(halves[0], scorer)
+		if len(halves[1]) > 0 {
+			odd[c], _, _ = BreakSingleByteXOrCipherWithScorer(halves[1], scorer)
+		}
+	}
+	pt := make([]byte, len(ct))
+	for i, b := range ct {
+		k := odd[i%keySize]
+		if (i/keySize)%2 == 1 {
+			k = even[i%keySize]
+		}
+		pt[i] = b ^ k
+	}
+	return scorer.Score(pt)
+}
+
+// shortestPeriod returns the shortest prefix of key that repeats to form
+// all of key.
+func shortestPeriod(key []byte) []byte {
+	for period := 1; period < len(key); period++ {
+		if len(key)%period != 0 {
+			continue
+		}
+		repeats := true
+		for i := period; i < len(key); i++ {
+			if key[i] != key[i-period] {
+				repeats = false
+				break
+			}
+		}
+		if repeats {
+			return key[:period]
+		}
+	}
 	return key
+}
+
+// transposeBlocks breaks ct into blocks of keySize length (the last block
+// may be shorter if ct % keySize != 0) and transposes them: it makes a block
+// that is the first byte of every block, and a block that is the second byte
+// of every block, and so on.
+func transposeBlocks(ct []byte, keySize int) [][]byte {
+	out := make([][]byte, keySize)
+	for i, b := range ct {
+		out[i%keySize] = append(out[i%keySize], b)
+	}
+	return out
+}
+
+/*
+RepeatingKeyXOrCandidates returns up to n candidate keys of length keySize,
+sorted from best to worst. Each transposed block is ranked with
+SingleByteXOrCandidates, and candidate keys are built from the best
+perColumn bytes of every block, so a column whose best byte is wrong can
+still be resolved by looking further down the list. A candidate's score is
+the sum of its columns' scores.
+*/
+func RepeatingKeyXOrCandidates(ct []byte, keySize, perColumn, n int, scorer Scorer) []XOrCandidate {
+	if keySize < 1 || perColumn < 1 || n < 1 {
+		return nil
+	}
+	var columns [][]XOrCandidate
+	for _, block := range transposeBlocks(ct, keySize) {
+		columns = append(columns, SingleByteXOrCandidates(block, scorer, perColumn))
+	}
+
+	//walk the combinations of column candidates best-first, starting from
+	//the best byte in every column and moving one column down at a time
+	combinationScore := func(indexes []byte) float64 {
+		var score float64
+		for col, i := range indexes {
+			score += columns[col][i].Score
+		}
+		return score
+	}
+	start := make([]byte, keySize)
+	h := &keyCombinationHeap{{start, combinationScore(start)}}
+	seen := map[string]bool{string(start): true}
+
+	var out []XOrCandidate
+	for h.Len() > 0 && len(out) < n {
+		c := heap.Pop(h).(keyCombination)
+		key := make([]byte, keySize)
+		for col, i := range c.indexes {
+			key[col] = columns[col][i].Key[0]
+		}
+		out = append(out, XOrCandidate{
+			Key:       key,
+			Plaintext: RepeatingKeyXOrCipher(ct, key),
+			Score:     c.score,
+		})
+
+		for col := range c.indexes {
+			if int(c.indexes[col])+1 >= len(columns[col]) {
+				continue
+			}
+			next := make([]byte, keySize)
+			copy(next, c.indexes)
+			next[col]++
+			if seen[string(next)] {
+				continue
+			}
+			seen[string(next)] = true
+			heap.Push(h, keyCombination{next, combinationScore(next)})
+		}
+	}
+	return out
+}
+
+// keyCombination is a choice of candidate index for every key column. The
+// indexes fit in a byte since there are only 256 candidates per column.
+type keyCombination struct {
+	indexes []byte
+	score   float64
+}
+
+type keyCombinationHeap []keyCombination
+
+func (h keyCombinationHeap) Len() int           { return len(h) }
+func (h keyCombinationHeap) Less(i, j int) bool { return h[i].score < h[j].score }
+func (h keyCombinationHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *keyCombinationHeap) Push(x interface{}) {
+	*h = append(*h, x.(keyCombination))
+}
 
+func (h *keyCombinationHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
 }
@@ -1,6 +1,6 @@
 package cryptopals
 
-import "crypto/aes"
+import "fmt"
 
 /*
 AES in ECB mode
@@ -20,21 +20,35 @@ You can obviously decrypt this using the OpenSSL command-line tool, but we're ha
 */
 
 func AESInECBModeDecrypt(ct, key []byte) []byte {
-	cipher, err := aes.NewCipher(key)
+	out, err := AESInECBModeDecryptChecked(ct, key)
 	if err != nil {
 		panic(err)
 	}
+	return out
+}
+
+// AESInECBModeDecryptChecked is AESInECBModeDecrypt, but returns an error
+// instead of panicking on a bad key or unaligned ciphertext.
+func AESInECBModeDecryptChecked(ct, key []byte) ([]byte, error) {
+	cipher, err := newAESCipher(key)
+	if err != nil {
+		return nil, err
+	}
 	bs := cipher.BlockSize() //Blocksize() should always return 16
 	if len(ct)%bs != 0 {
-		panic("Ciphertext length needs to be a multiple of the blocksize")
+		return nil, fmt.Errorf("%w: ciphertext is %d bytes", ErrNotBlockAligned, len(ct))
 	}
-	dst := make([]byte, bs)
-	var out []byte
+	out := make([]byte, len(ct))
+	NewECBDecrypter(cipher).CryptBlocks(out, ct)
+	return out, nil
+}
 
-	for i := 0; i < len(ct); i += bs {
-		cipher.Decrypt(dst, ct[i:i+bs])
-		out = append(out, dst[:]...)
+// AESInECBModeDecryptWithPadding decrypts ct in ECB mode and removes the
+// padding.
+func AESInECBModeDecryptWithPadding(ct, key []byte, padding Padding) ([]byte, error) {
+	cipher, err := newAESCipher(key)
+	if err != nil {
+		return nil, err
 	}
-
-	return out
+	return DecryptWithPadding(NewECBDecrypter(cipher), padding, ct)
 }
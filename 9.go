@@ -17,19 +17,13 @@ So: pad any block to a specific block length, by appending the number of bytes o
 */
 
 /*
-Pad implements PKS#7 padding. It returns a padded version of pt based on blockSize.
+Pad implements PKS#7 padding. It returns a padded copy of pt based on
+blockSize, and panics if blockSize isn't between 1 and 255.
 */
 func Pad(pt []byte, blockSize int) []byte {
-	paddingLength := blockSize - (len(pt) % blockSize)
-	//if the last block doesn't need any padding,
-	//create a block that only contains padding
-	//bytes
-	if paddingLength == 0 {
-		paddingLength = blockSize
+	out, err := PKCS7Padding{}.Pad(pt, blockSize)
+	if err != nil {
+		panic(err)
 	}
-
-	for i := 0; i < paddingLength; i++ {
-		pt = append(pt, byte(paddingLength))
-	}
-	return pt
+	return out
 }
@@ -0,0 +1,139 @@
+package cryptopals
+
+import (
+	"fmt"
+	"strings"
+)
+
+/*
+Bit-flipping attacks on CBC and CTR (challenges 16 and 26), worked out for
+any target string. The oracle escapes some characters of its input, so the
+target can't just be sent as is. Only those bytes of the target are sent as
+an 'A' and fixed up in the ciphertext afterwards; the rest, spaces
+included, are sent as they are.
+
+In CTR mode, flipping a bit of the ciphertext flips the same bit of the
+plaintext and nothing else. In CBC mode it flips the same bit in the next
+block of plaintext, and scrambles the block it's in. So in CBC the target
+starts a block, the block before it is filler that gets sacrificed, and
+every byte that needs fixing has to be in that first block of the target;
+the rest of the target can go on for as many blocks as it likes.
+*/
+
+// ByteFlip is a change to make to the ciphertext: XOR the byte at Offset
+// with Delta.
+type ByteFlip struct {
+	Offset int
+	Delta  byte
+}
+
+/*
+BitflipPlan is a worked out bit-flipping attack: send Input to the oracle,
+then apply Flips to the ciphertext it returns. In CBC mode the flips
+scramble plaintext block ScrambledBlock, which is filler; it's -1 in CTR
+mode.
+*/
+type BitflipPlan struct {
+	Input          []byte
+	Flips          []ByteFlip
+	ScrambledBlock int
+}
+
+// bitflipSubstitute is sent in place of target bytes that need flipping.
+const bitflipSubstitute = 'A'
+
+// BitflipFiltered is the bytes the challenge 16 and 26 oracles quote out of
+// their input.
+const BitflipFiltered = ";="
+
+/*
+PlanBitflip works out a bit-flipping attack that puts target into the
+plaintext, for an oracle that puts prefixLength bytes in front of its
+input and quotes out the bytes in BitflipFiltered. mode is ModeCBC or
+ModeStream; blockSize is only used for CBC. It returns an error wrapping
+ErrCannotFlip if the target needs bytes fixing more than a block apart in
+CBC mode.
+*/
+func PlanBitflip(mode CipherMode, prefixLength, blockSize int, target []byte) (BitflipPlan, error) {
+	return PlanBitflipFiltered(mode, prefixLength, blockSize, target, BitflipFiltered)
+}
+
+// PlanBitflipFiltered is PlanBitflip for an oracle that filters out the
+// bytes in filtered, which can't include the 'A' sent in their place.
+func PlanBitflipFiltered(mode CipherMode, prefixLength, blockSize int, target []byte, filtered string) (BitflipPlan, error) {
+	if prefixLength < 0 {
+		return BitflipPlan{}, fmt.Errorf("%w: prefix length %d", ErrInvalidLength, prefixLength)
+	}
+	if strings.IndexByte(filtered, bitflipSubstitute) >= 0 {
+		return BitflipPlan{}, fmt.Errorf("%w: %q is filtered too", ErrCannotFlip, bitflipSubstitute)
+	}
+	input := make([]byte, len(target))
+	var fix []int
+	for i, b := range target {
+		if strings.IndexByte(filtered, b) < 0 {
+			input[i] = b
+		} else {
+			input[i] = bitflipSubstitute
+			fix = append(fix, i)
+		}
+	}
+
+	plan := BitflipPlan{ScrambledBlock: -1}
+	start := prefixLength
+	switch mode {
+	case ModeStream:
+		plan.Input = input
+	case ModeCBC:
+		if blockSize < 1 {
+			return BitflipPlan{}, fmt.Errorf("%w: %d", ErrInvalidBlockSize, blockSize)
+		}
+		if len(fix) > 0 && fix[len(fix)-1] >= blockSize {
+			return BitflipPlan{}, fmt.Errorf("%w: byte %d of the target needs fixing, but only the first %d can be",
+				ErrCannotFlip, fix[len(fix)-1], blockSize)
+		}
+		//the target starts the block after the first whole block of filler
+		k := (prefixLength+blockSize-1)/blockSize + 1
+		start = k * blockSize
+		plan.Input = append(fill(ecbFiller, start-prefixLength), input...)
+		if len(fix) > 0 {
+			plan.ScrambledBlock = k - 1
+		}
+		start -= blockSize
+	default:
+		return BitflipPlan{}, fmt.Errorf("%w: can't flip bits in %v mode", ErrCannotFlip, mode)
+	}
+
+	for _, i := range fix {
+		plan.Flips = append(plan.Flips, ByteFlip{start + i, bitflipSubstitute ^ target[i]})
+	}
+	return plan, nil
+}
+
+/*
+PlanBitflipFromOracle is PlanBitflip for an oracle whose prefix isn't known.
+It runs DetectMode on the oracle to find the prefix length and block size,
+and checks that the oracle uses the mode expected. The oracle has to be
+deterministic, with a fixed IV or nonce, for the prefix to be found.
+*/
+func PlanBitflipFromOracle(oracle func([]byte) []byte, mode CipherMode, target []byte) (BitflipPlan, error) {
+	r := DetectMode(oracle)
+	if r.Mode != mode {
+		return BitflipPlan{}, fmt.Errorf("%w: expected %v, detected %v", ErrUnexpectedOracle, mode, r)
+	}
+	if r.PrefixLength < 0 {
+		return BitflipPlan{}, fmt.Errorf("%w: couldn't find the prefix length: %v", ErrUnexpectedOracle, r)
+	}
+	return PlanBitflip(mode, r.PrefixLength, r.BlockSize, target)
+}
+
+// Apply returns a copy of ct with the flips made.
+func (p BitflipPlan) Apply(ct []byte) ([]byte, error) {
+	out := append([]byte{}, ct...)
+	for _, f := range p.Flips {
+		if f.Offset >= len(out) {
+			return nil, fmt.Errorf("%w: flip at %d in a %d byte ciphertext", ErrInvalidLength, f.Offset, len(out))
+		}
+		out[f.Offset] ^= f.Delta
+	}
+	return out, nil
+}
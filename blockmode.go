@@ -0,0 +1,118 @@
+package cryptopals
+
+import "crypto/cipher"
+
+/*
+Hand-written ECB and CBC as crypto/cipher BlockModes, so they work over any
+cipher.Block (AES, DES, 3DES or a toy cipher) and can be fed a stream a few
+blocks at a time. Like the standard library's modes, CryptBlocks panics if
+src isn't a whole number of blocks or dst is too short, and dst and src may
+overlap exactly.
+
+The CBC modes keep the last ciphertext block between calls, so encrypting
+or decrypting a message in pieces gives the same result as doing it all at
+once.
+*/
+
+type ecbEncrypter struct {
+	b cipher.Block
+}
+
+type ecbDecrypter struct {
+	b cipher.Block
+}
+
+// NewECBEncrypter returns a BlockMode that encrypts in ECB mode with b.
+func NewECBEncrypter(b cipher.Block) cipher.BlockMode {
+	return &ecbEncrypter{b}
+}
+
+// NewECBDecrypter returns a BlockMode that decrypts in ECB mode with b.
+func NewECBDecrypter(b cipher.Block) cipher.BlockMode {
+	return &ecbDecrypter{b}
+}
+
+func (x *ecbEncrypter) BlockSize() int { return x.b.BlockSize() }
+func (x *ecbDecrypter) BlockSize() int { return x.b.BlockSize() }
+
+func checkBlocks(bs int, dst, src []byte) {
+	if len(src)%bs != 0 {
+		panic("cryptopals: input not full blocks")
+	}
+	if len(dst) < len(src) {
+		panic("cryptopals: output smaller than input")
+	}
+}
+
+func (x *ecbEncrypter) CryptBlocks(dst, src []byte) {
+	bs := x.b.BlockSize()
+	checkBlocks(bs, dst, src)
+	for i := 0; i < len(src); i += bs {
+		x.b.Encrypt(dst[i:i+bs], src[i:i+bs])
+	}
+}
+
+func (x *ecbDecrypter) CryptBlocks(dst, src []byte) {
+	bs := x.b.BlockSize()
+	checkBlocks(bs, dst, src)
+	for i := 0; i < len(src); i += bs {
+		x.b.Decrypt(dst[i:i+bs], src[i:i+bs])
+	}
+}
+
+type cbcEncrypter struct {
+	b    cipher.Block
+	prev []byte
+}
+
+type cbcDecrypter struct {
+	b    cipher.Block
+	prev []byte
+}
+
+func copyIV(b cipher.Block, iv []byte) []byte {
+	if len(iv) != b.BlockSize() {
+		panic("cryptopals: IV length must equal block size")
+	}
+	prev := make([]byte, len(iv))
+	copy(prev, iv)
+	return prev
+}
+
+// NewCBCEncrypter returns a BlockMode that encrypts in CBC mode with b,
+// starting from iv. It panics if iv isn't one block long.
+func NewCBCEncrypter(b cipher.Block, iv []byte) cipher.BlockMode {
+	return &cbcEncrypter{b, copyIV(b, iv)}
+}
+
+// NewCBCDecrypter returns a BlockMode that decrypts in CBC mode with b,
+// starting from iv. It panics if iv isn't one block long.
+func NewCBCDecrypter(b cipher.Block, iv []byte) cipher.BlockMode {
+	return &cbcDecrypter{b, copyIV(b, iv)}
+}
+
+func (x *cbcEncrypter) BlockSize() int { return x.b.BlockSize() }
+func (x *cbcDecrypter) BlockSize() int { return x.b.BlockSize() }
+
+func (x *cbcEncrypter) CryptBlocks(dst, src []byte) {
+	bs := x.b.BlockSize()
+	checkBlocks(bs, dst, src)
+	for i := 0; i < len(src); i += bs {
+		block := XOr(src[i:i+bs], x.prev)
+		x.b.Encrypt(dst[i:i+bs], block)
+		copy(x.prev, dst[i:i+bs])
+	}
+}
+
+func (x *cbcDecrypter) CryptBlocks(dst, src []byte) {
+	bs := x.b.BlockSize()
+	checkBlocks(bs, dst, src)
+	ct := make([]byte, bs)
+	for i := 0; i < len(src); i += bs {
+		//hold on to the ciphertext block in case dst and src overlap
+		copy(ct, src[i:i+bs])
+		x.b.Decrypt(dst[i:i+bs], ct)
+		copy(dst[i:i+bs], XOr(dst[i:i+bs], x.prev))
+		copy(x.prev, ct)
+	}
+}
@@ -0,0 +1,258 @@
+/*
+Command ctrsolve is an interactive helper for breaking a set of ciphertexts
+that were encrypted under the same keystream, such as CTR with a fixed
+nonce (challenge 19).
+
+Usage:
+
+	ctrsolve [-encrypt] [-session file] ciphertexts.txt
+
+The input file has one base64 ciphertext per line. With -encrypt, the lines
+are taken to be plaintexts (like input/19.txt) and are first encrypted
+under a random key with a zero nonce. With -session, a saved session is
+loaded instead and the input file is ignored.
+
+The ciphertexts are shown as a grid, one row per ciphertext. Type guesses
+at the plaintext of any row and every row re-decrypts with the updated
+keystream. Type "help" for the list of commands.
+*/
+package main
+
+import (
+	"bufio"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/northwestbased/cryptopals"
+)
+
+const usage = `commands:
+  g ROW COL TEXT   guess that row ROW has plaintext TEXT starting at column COL
+  c COL [N]        forget N keystream bytes (default 1) starting at column COL
+  s COL [N]        show the top N suggestions (default 5) for column COL
+  a                fill every unknown column with its top suggestion
+  w FILE           save the session to FILE
+  r FILE           load a session from FILE
+  p                print the grid
+  q                quit
+`
+
+func main() {
+	encrypt := flag.Bool("encrypt", false, "treat the input lines as plaintexts and encrypt them under a fixed nonce")
+	sessionFile := flag.String("session", "", "load a saved session instead of an input file")
+	flag.Parse()
+
+	var session *cryptopals.FixedNonceSession
+	var err error
+	if *sessionFile != "" {
+		session, err = cryptopals.LoadFixedNonceSession(*sessionFile)
+	} else if flag.NArg() == 1 {
+		session, err = newSession(flag.Arg(0), *encrypt)
+	} else {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	run(session, os.Stdin, os.Stdout)
+}
+
+func newSession(filename string, encrypt bool) (*cryptopals.FixedNonceSession, error) {
+	lines, err := cryptopals.ReadFileByLine(filename)
+	if err != nil {
+		return nil, err
+	}
+	key := cryptopals.Key(16)
+	nonce := make([]byte, 8)
+	var cts [][]byte
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		b, err := base64.StdEncoding.DecodeString(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %v: %v", i+1, err)
+		}
+		if encrypt {
+			b = cryptopals.CTR_Cipher(b, key, nonce)
+		}
+		cts = append(cts, b)
+	}
+	return cryptopals.NewFixedNonceSession(cts), nil
+}
+
+func run(session *cryptopals.FixedNonceSession, in io.Reader, out io.Writer) {
+	scorer := cryptopals.ChiSquaredScorer{}
+	printGrid(out, session)
+	scanner := bufio.NewScanner(in)
+	for {
+		fmt.Fprint(out, "> ")
+		if !scanner.Scan() {
+			return
+		}
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		var err error
+		redraw := true
+		switch fields[0] {
+		case "g":
+			err = guess(session, scanner.Text(), out)
+		case "c":
+			var col, n int
+			col, n, err = columnAndCount(fields, 1)
+			if err == nil {
+				session.Clear(col, n)
+			}
+		case "s":
+			var col, n int
+			col, n, err = columnAndCount(fields, 5)
+			if err == nil {
+				for _, c := range session.Suggest(col, scorer, n) {
+					fmt.Fprintf(out, "  key %02x  score %8.3f  %q\n", c.Key[0], c.Score, c.Plaintext)
+				}
+			}
+			redraw = false
+		case "a":
+			session.AutoFill(scorer)
+		case "w":
+			if len(fields) != 2 {
+				err = fmt.Errorf("usage: w FILE")
+			} else {
+				err = session.Save(fields[1])
+			}
+			redraw = false
+		case "r":
+			if len(fields) != 2 {
+				err = fmt.Errorf("usage: r FILE")
+			} else {
+				var loaded *cryptopals.FixedNonceSession
+				loaded, err = cryptopals.LoadFixedNonceSession(fields[1])
+				if err == nil {
+					*session = *loaded
+				}
+			}
+		case "p":
+		case "q":
+			return
+		default:
+			fmt.Fprint(out, usage)
+			redraw = false
+		}
+
+		if err != nil {
+			fmt.Fprintln(out, "error:", err)
+		} else if redraw {
+			printGrid(out, session)
+		}
+	}
+}
+
+// guess handles "g ROW COL TEXT". The command and numbers can be separated
+// by any run of spaces or tabs; TEXT is everything after the single space
+// or tab that ends the column number, so it may contain (or start with)
+// spaces.
+func guess(session *cryptopals.FixedNonceSession, line string, out io.Writer) error {
+	parts, text := cutFields(line, 3)
+	if len(parts) != 3 || text == "" {
+		return fmt.Errorf("usage: g ROW COL TEXT")
+	}
+	parts = append(parts, text)
+	row, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return err
+	}
+	col, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return err
+	}
+	conflicts, err := session.Guess(row, col, []byte(parts[3]))
+	if err != nil {
+		return err
+	}
+	if len(conflicts) > 0 {
+		fmt.Fprintf(out, "replaced known keystream at columns %v\n", conflicts)
+	}
+	return nil
+}
+
+// cutFields splits off the first n whitespace-separated fields of line, and
+// returns them with the rest of the line after the separator that follows
+// the last of them.
+func cutFields(line string, n int) ([]string, string) {
+	var fields []string
+	rest := line
+	for len(fields) < n {
+		rest = strings.TrimLeft(rest, " \t")
+		if rest == "" {
+			break
+		}
+		end := strings.IndexAny(rest, " \t")
+		if end < 0 {
+			fields = append(fields, rest)
+			return fields, ""
+		}
+		fields = append(fields, rest[:end])
+		rest = rest[end+1:]
+	}
+	return fields, rest
+}
+
+func columnAndCount(fields []string, defaultCount int) (int, int, error) {
+	if len(fields) < 2 || len(fields) > 3 {
+		return 0, 0, fmt.Errorf("usage: %v COL [N]", fields[0])
+	}
+	col, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return 0, 0, err
+	}
+	n := defaultCount
+	if len(fields) == 3 {
+		if n, err = strconv.Atoi(fields[2]); err != nil {
+			return 0, 0, err
+		}
+	}
+	return col, n, nil
+}
+
+// printGrid shows every row with its columns lined up under a ruler.
+// Unknown bytes are shown as '_' and unprintable ones as '.'.
+func printGrid(out io.Writer, session *cryptopals.FixedNonceSession) {
+	width := session.Width()
+	var tens, ones strings.Builder
+	for col := 0; col < width; col++ {
+		if col%10 == 0 {
+			tens.WriteString(strconv.Itoa(col / 10 % 10))
+		} else {
+			tens.WriteByte(' ')
+		}
+		ones.WriteString(strconv.Itoa(col % 10))
+	}
+	fmt.Fprintf(out, "     %v\n     %v\n", tens.String(), ones.String())
+
+	pts, known := session.Plaintexts()
+	for row, pt := range pts {
+		line := make([]byte, len(pt))
+		for i, b := range pt {
+			switch {
+			case !known[row][i]:
+				line[i] = '_'
+			case b < 0x20 || b >= 0x7f:
+				line[i] = '.'
+			default:
+				line[i] = b
+			}
+		}
+		fmt.Fprintf(out, "%3d  %s\n", row, line)
+	}
+}
@@ -0,0 +1,105 @@
+package cryptopals
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+/*
+Cookie is a structured cookie in the k=v format from challenge 13:
+
+	email=foo@bar.com&uid=10&role=user
+
+The fields are kept in order, and a key can appear more than once. Keys
+and values are escaped when encoding, so '&', '=' and '%' in them can't
+change the structure: each is written as '%' followed by two hex digits.
+*/
+type Cookie []CookieField
+
+// CookieField is one k=v pair of a Cookie.
+type CookieField struct {
+	Key, Value string
+}
+
+// cookieMeta are the characters escaped in keys and values.
+const cookieMeta = "&=%"
+
+func escapeCookie(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if strings.IndexByte(cookieMeta, s[i]) >= 0 {
+			fmt.Fprintf(&sb, "%%%02X", s[i])
+		} else {
+			sb.WriteByte(s[i])
+		}
+	}
+	return sb.String()
+}
+
+func unescapeCookie(s string) (string, error) {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' {
+			sb.WriteByte(s[i])
+			continue
+		}
+		if i+3 > len(s) {
+			return "", fmt.Errorf("%w: truncated escape in %q", ErrInvalidCookie, s)
+		}
+		b, err := strconv.ParseUint(s[i+1:i+3], 16, 8)
+		if err != nil {
+			return "", fmt.Errorf("%w: bad escape in %q", ErrInvalidCookie, s)
+		}
+		sb.WriteByte(byte(b))
+		i += 2
+	}
+	return sb.String(), nil
+}
+
+// Encode returns the cookie in k=v format.
+func (c Cookie) Encode() string {
+	fields := make([]string, len(c))
+	for i, f := range c {
+		fields[i] = escapeCookie(f.Key) + "=" + escapeCookie(f.Value)
+	}
+	return strings.Join(fields, "&")
+}
+
+// Get returns the value of the first field with the given key.
+func (c Cookie) Get(key string) (string, bool) {
+	for _, f := range c {
+		if f.Key == key {
+			return f.Value, true
+		}
+	}
+	return "", false
+}
+
+/*
+ParseCookie parses a cookie in k=v format. Every field needs exactly one
+'=', and escapes have to be well formed; anything else is an error
+wrapping ErrInvalidCookie. An empty string is a cookie with no fields.
+*/
+func ParseCookie(s string) (Cookie, error) {
+	if s == "" {
+		return Cookie{}, nil
+	}
+	var c Cookie
+	for _, field := range strings.Split(s, "&") {
+		kv := strings.Split(field, "=")
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("%w: field %q isn't k=v", ErrInvalidCookie, field)
+		}
+		key, err := unescapeCookie(kv[0])
+		if err != nil {
+			return nil, err
+		}
+		value, err := unescapeCookie(kv[1])
+		if err != nil {
+			return nil, err
+		}
+		c = append(c, CookieField{key, value})
+	}
+	return c, nil
+}
@@ -0,0 +1,237 @@
+package cryptopals
+
+import "sort"
+
+/*
+Crib dragging
+
+Every XOR stream cipher that reuses its keystream - repeating-key XOR,
+CTR with a fixed nonce, MT19937StreamCipher with the same seed - gives
+away the keystream wherever we know the plaintext:
+
+CIPHERTEXT-BYTE XOR PLAINTEXT-BYTE = KEYSTREAM-BYTE
+
+So if we guess a fragment of plaintext (a "crib") and where it sits, we get
+a piece of keystream, and that piece decrypts the same positions of every
+other ciphertext. Sliding the crib along and checking whether those other
+positions look like plaintext tells us where the crib fits.
+
+Keystream positions are either shared from the start of every ciphertext
+(period 0: fixed-nonce CTR, a reused MT19937 seed), or repeat every period
+bytes (repeating-key XOR, which usually means a single ciphertext).
+*/
+
+// CribMatch is one placement of a crib that made the rest of the
+// ciphertexts decrypt to something plausible.
+type CribMatch struct {
+	Crib       []byte
+	Ciphertext int // index of the ciphertext the crib was placed in
+	Offset     int // where in that ciphertext the crib starts
+	Keystream  []byte
+	// Fragments are the other bytes that Keystream decrypts.
+	Fragments [][]byte
+	Score     float64
+}
+
+// keystreamIndex maps a ciphertext offset to a keystream position.
+func keystreamIndex(offset, period int) int {
+	if period > 0 {
+		return offset % period
+	}
+	return offset
+}
+
+/*
+DragCrib slides crib over every offset of every ciphertext. Each placement
+is scored by running scorer over the fragments its keystream decrypts,
+weighted by fragment length, and placements scoring at or below threshold
+are returned, best first. Placements that decrypt nothing else, and (with
+a period) cribs that would need two different key bytes at the same
+position, are skipped.
+*/
+func DragCrib(cts [][]byte, crib []byte, period int, scorer Scorer, threshold float64) []CribMatch {
+	var matches []CribMatch
+	if len(crib) == 0 {
+		return matches
+	}
+	for i, ct := range cts {
+		for offset := 0; offset+len(crib) <= len(ct); offset++ {
+			keystream := XOr(ct[offset:offset+len(crib)], crib)
+			if period > 0 && !consistentWithPeriod(keystream, period) {
+				continue
+			}
+			fragments := cribFragments(cts, i, offset, keystream, period)
+			if len(fragments) == 0 {
+				continue
+			}
+
+			var score float64
+			total := 0
+			for _, f := range fragments {
+				score += scorer.Score(f) * float64(len(f))
+				total += len(f)
+			}
+			score /= float64(total)
+
+			if score <= threshold {
+				matches = append(matches, CribMatch{
+					Crib:       crib,
+					Ciphertext: i,
+					Offset:     offset,
+					Keystream:  keystream,
+					Fragments:  fragments,
+					Score:      score,
+				})
+			}
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].Score < matches[j].Score
+	})
+	return matches
+}
+
+func consistentWithPeriod(keystream []byte, period int) bool {
+	for i := period; i < len(keystream); i++ {
+		if keystream[i] != keystream[i-period] {
+			return false
+		}
+	}
+	return true
+}
+
+// cribFragments decrypts every run of ciphertext that lines up with the
+// keystream found at cts[i][offset:], leaving out the crib itself.
+func cribFragments(cts [][]byte, i, offset int, keystream []byte, period int) [][]byte {
+	var fragments [][]byte
+	for j, ct := range cts {
+		//with a period the keystream lines up every period bytes, otherwise
+		//only at the same offset
+		starts := []int{offset}
+		if period > 0 {
+			starts = nil
+			for s := offset % period; s < len(ct); s += period {
+				starts = append(starts, s)
+			}
+			//a run may start before 0 and still overlap the ciphertext
+			for s := offset%period - period; s+len(keystream) > 0; s -= period {
+				starts = append(starts, s)
+			}
+		}
+		for _, s := range starts {
+			var fragment []byte
+			for k := range keystream {
+				pos := s + k
+				if pos < 0 || pos >= len(ct) {
+					continue
+				}
+				if j == i && pos >= offset && pos < offset+len(keystream) {
+					continue
+				}
+				fragment = append(fragment, ct[pos]^keystream[k])
+			}
+			if len(fragment) > 0 {
+				fragments = append(fragments, fragment)
+			}
+		}
+	}
+	return fragments
+}
+
+// KeystreamProvenance records which crib placement a keystream byte came
+// from.
+type KeystreamProvenance struct {
+	Crib       []byte
+	Ciphertext int
+	Offset     int
+}
+
+/*
+PartialKeystream is a keystream that's being recovered a piece at a time.
+Every known byte remembers which crib placement it came from, so a bad
+guess can be traced and backed out.
+*/
+type PartialKeystream struct {
+	Period     int
+	Bytes      []byte
+	Known      []bool
+	Provenance []KeystreamProvenance
+}
+
+// NewPartialKeystream creates an empty keystream. A period of 0 means
+// keystream positions line up with ciphertext offsets.
+func NewPartialKeystream(period int) *PartialKeystream {
+	ks := &PartialKeystream{Period: period}
+	if period > 0 {
+		ks.grow(period)
+	}
+	return ks
+}
+
+func (ks *PartialKeystream) grow(length int) {
+	for len(ks.Bytes) < length {
+		ks.Bytes = append(ks.Bytes, 0)
+		ks.Known = append(ks.Known, false)
+		ks.Provenance = append(ks.Provenance, KeystreamProvenance{})
+	}
+}
+
+/*
+Set records a single keystream byte at a ciphertext offset. It returns
+true if this replaced a different known value.
+*/
+func (ks *PartialKeystream) Set(offset int, value byte, p KeystreamProvenance) bool {
+	index := keystreamIndex(offset, ks.Period)
+	ks.grow(index + 1)
+	conflict := ks.Known[index] && ks.Bytes[index] != value
+	ks.Bytes[index] = value
+	ks.Known[index] = true
+	ks.Provenance[index] = p
+	return conflict
+}
+
+/*
+AddCrib records the keystream from a crib match, and returns the
+ciphertext offsets where it disagreed with what was already known. The
+new values win.
+*/
+func (ks *PartialKeystream) AddCrib(m CribMatch) []int {
+	var conflicts []int
+	p := KeystreamProvenance{m.Crib, m.Ciphertext, m.Offset}
+	for k, b := range m.Keystream {
+		if ks.Set(m.Offset+k, b, p) {
+			conflicts = append(conflicts, m.Offset+k)
+		}
+	}
+	return conflicts
+}
+
+// Decrypt decrypts ct as far as the keystream is known. The second return
+// value says which plaintext bytes are real; the rest are left as zero.
+func (ks *PartialKeystream) Decrypt(ct []byte) ([]byte, []bool) {
+	pt := make([]byte, len(ct))
+	known := make([]bool, len(ct))
+	for i, c := range ct {
+		index := keystreamIndex(i, ks.Period)
+		if index < len(ks.Bytes) && ks.Known[index] {
+			pt[i] = c ^ ks.Bytes[index]
+			known[i] = true
+		}
+	}
+	return pt, known
+}
+
+// Coverage returns the fraction of the first length keystream positions
+// that are known.
+func (ks *PartialKeystream) Coverage(length int) float64 {
+	if length <= 0 {
+		return 0
+	}
+	known := 0
+	for i := 0; i < length && i < len(ks.Known); i++ {
+		if ks.Known[i] {
+			known++
+		}
+	}
+	return float64(known) / float64(length)
+}
@@ -0,0 +1,214 @@
+package cryptopals
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+/*
+CTR mode with a configurable counter block. The counter is always the last
+CounterBits of the block, and whatever comes before it is the nonce:
+
+  - challenge 18 uses an 8-byte nonce and a 64-bit little-endian counter
+  - NIST SP 800-38A (and crypto/cipher.NewCTR) count over the whole 128-bit
+    block, big-endian
+  - GCM and RFC 3686 use a 96-bit nonce and a 32-bit big-endian counter
+*/
+
+// CounterLayout describes where the counter is in a CTR counter block and
+// how it counts.
+type CounterLayout struct {
+	//CounterBits is 32, 64 or 128
+	CounterBits  int
+	LittleEndian bool
+	//AllowWrap lets the counter wrap around to 0 instead of failing, as
+	//GCM's inc32 does
+	AllowWrap bool
+}
+
+// The counter layouts in common use.
+var (
+	CTRLayoutChallenge18 = CounterLayout{CounterBits: 64, LittleEndian: true}
+	CTRLayoutNIST        = CounterLayout{CounterBits: 128}
+	CTRLayoutRFC3686     = CounterLayout{CounterBits: 32}
+)
+
+// CTR is a CTR mode keystream. It implements cipher.Stream.
+type CTR struct {
+	block     cipher.Block
+	layout    CounterLayout
+	counter   []byte
+	keystream []byte
+	//used is how much of keystream has already been XORed
+	used int
+	//exhausted is set once the counter has gone past its largest value
+	exhausted bool
+}
+
+var _ cipher.Stream = (*CTR)(nil)
+
+func checkCounterLayout(layout CounterLayout, blockSize int) error {
+	switch layout.CounterBits {
+	case 32, 64, 128:
+	default:
+		return fmt.Errorf("%w: %d bit counter", ErrInvalidCounter, layout.CounterBits)
+	}
+	if layout.CounterBits > 8*blockSize {
+		return fmt.Errorf("%w: %d bit counter in a %d byte block", ErrInvalidCounter, layout.CounterBits, blockSize)
+	}
+	return nil
+}
+
+/*
+NewCTR returns a CTR keystream starting at the counter block iv, which has
+to be the block size. It returns an error wrapping ErrInvalidCounter if the
+layout isn't one of the widths supported or doesn't fit in the block.
+*/
+func NewCTR(block cipher.Block, iv []byte, layout CounterLayout) (*CTR, error) {
+	bs := block.BlockSize()
+	if err := checkCounterLayout(layout, bs); err != nil {
+		return nil, err
+	}
+	if len(iv) != bs {
+		return nil, fmt.Errorf("%w: %d bytes, expected %d", ErrInvalidIVSize, len(iv), bs)
+	}
+	counter := make([]byte, bs)
+	copy(counter, iv)
+	return &CTR{block: block, layout: layout, counter: counter, keystream: make([]byte, bs), used: bs}, nil
+}
+
+/*
+NewCTRWithNonce returns a CTR keystream whose counter blocks are nonce
+followed by a counter starting at start. The nonce has to fill the rest of
+the block, so it's empty for a 128-bit counter with a 16-byte block.
+*/
+func NewCTRWithNonce(block cipher.Block, nonce []byte, start uint64, layout CounterLayout) (*CTR, error) {
+	bs := block.BlockSize()
+	if err := checkCounterLayout(layout, bs); err != nil {
+		return nil, err
+	}
+	width := layout.CounterBits / 8
+	if len(nonce) != bs-width {
+		return nil, fmt.Errorf("%w: %d bytes, expected %d", ErrInvalidNonceSize, len(nonce), bs-width)
+	}
+	if width == 4 && start > math.MaxUint32 {
+		return nil, fmt.Errorf("%w: %d doesn't fit in a 32 bit counter", ErrInvalidCounter, start)
+	}
+	iv := make([]byte, bs)
+	copy(iv, nonce)
+	//start sets the counter's low 64 bits, or all 32 of a 32-bit one
+	low := make([]byte, 8)
+	n := width
+	if n > 8 {
+		n = 8
+	}
+	if layout.LittleEndian {
+		binary.LittleEndian.PutUint64(low, start)
+		copy(iv[bs-width:], low[:n])
+	} else {
+		binary.BigEndian.PutUint64(low, start)
+		copy(iv[bs-n:], low[8-n:])
+	}
+	return NewCTR(block, iv, layout)
+}
+
+// Counter returns a copy of the counter block the next keystream block
+// will be made from.
+func (c *CTR) Counter() []byte {
+	return append([]byte{}, c.counter...)
+}
+
+// counterByte returns the index into the counter block of the i'th least
+// significant byte of the counter.
+func (c *CTR) counterByte(i int) int {
+	bs := len(c.counter)
+	if c.layout.LittleEndian {
+		return bs - c.layout.CounterBits/8 + i
+	}
+	return bs - 1 - i
+}
+
+// increment adds one to the counter, and notes when it wraps around.
+func (c *CTR) increment() {
+	for i := 0; i < c.layout.CounterBits/8; i++ {
+		j := c.counterByte(i)
+		c.counter[j]++
+		if c.counter[j] != 0 {
+			return
+		}
+	}
+	if !c.layout.AllowWrap {
+		c.exhausted = true
+	}
+}
+
+/*
+remainingBlocks returns how many more keystream blocks the counter can make
+before it wraps around, saturating at math.MaxUint64.
+*/
+func (c *CTR) remainingBlocks() uint64 {
+	if c.layout.AllowWrap {
+		return math.MaxUint64
+	}
+	if c.exhausted {
+		return 0
+	}
+	width := c.layout.CounterBits / 8
+	n := width
+	if n > 8 {
+		n = 8
+	}
+	for i := 8; i < width; i++ {
+		if c.counter[c.counterByte(i)] != 0xff {
+			return math.MaxUint64
+		}
+	}
+	//the blocks left are the counter's largest value minus its current
+	//one, plus one for the current one
+	var left uint64
+	for i := n - 1; i >= 0; i-- {
+		left = left<<8 | uint64(^c.counter[c.counterByte(i)])
+	}
+	if left == math.MaxUint64 {
+		return left
+	}
+	return left + 1
+}
+
+/*
+XORKeyStreamChecked XORs src with the keystream into dst, which may
+overlap src entirely or not at all. It returns an error wrapping
+ErrCounterWrapped, without touching dst, if the counter would wrap around
+and the layout doesn't allow it.
+*/
+func (c *CTR) XORKeyStreamChecked(dst, src []byte) error {
+	if len(dst) < len(src) {
+		return fmt.Errorf("%w: %d byte output for %d bytes of input", ErrLengthMismatch, len(dst), len(src))
+	}
+	bs := len(c.counter)
+	need := len(src) - (bs - c.used)
+	if need > 0 && uint64((need+bs-1)/bs) > c.remainingBlocks() {
+		return fmt.Errorf("%w: %d bytes of keystream needed, %d left",
+			ErrCounterWrapped, len(src), uint64(bs-c.used)+c.remainingBlocks()*uint64(bs))
+	}
+	for i := range src {
+		if c.used == bs {
+			c.block.Encrypt(c.keystream, c.counter)
+			c.increment()
+			c.used = 0
+		}
+		dst[i] = src[i] ^ c.keystream[c.used]
+		c.used++
+	}
+	return nil
+}
+
+// XORKeyStream is XORKeyStreamChecked for cipher.Stream. It panics if the
+// counter wraps around.
+func (c *CTR) XORKeyStream(dst, src []byte) {
+	if err := c.XORKeyStreamChecked(dst, src); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,154 @@
+package cryptopals
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+/*
+FixedNonceSession is the state of a by-hand attack on a set of ciphertexts
+that share one keystream, as in challenge 19. Guesses at the plaintext of
+any row fill in the keystream, which in turn decrypts the same columns of
+every other row. Sessions can be saved to and loaded from JSON files so a
+solve can be picked up later.
+*/
+type FixedNonceSession struct {
+	Ciphertexts [][]byte
+	Keystream   *PartialKeystream
+}
+
+// NewFixedNonceSession starts a session with nothing known.
+func NewFixedNonceSession(cts [][]byte) *FixedNonceSession {
+	return &FixedNonceSession{
+		Ciphertexts: cts,
+		Keystream:   NewPartialKeystream(0),
+	}
+}
+
+// Width returns the length of the longest ciphertext.
+func (s *FixedNonceSession) Width() int {
+	width := 0
+	for _, ct := range s.Ciphertexts {
+		if len(ct) > width {
+			width = len(ct)
+		}
+	}
+	return width
+}
+
+/*
+Guess sets the plaintext of row starting at column col, and returns the
+columns where this changed a keystream byte that was already known.
+*/
+func (s *FixedNonceSession) Guess(row, col int, text []byte) ([]int, error) {
+	if row < 0 || row >= len(s.Ciphertexts) {
+		return nil, fmt.Errorf("row %v out of range", row)
+	}
+	ct := s.Ciphertexts[row]
+	if col < 0 || col+len(text) > len(ct) {
+		return nil, fmt.Errorf("row %v is only %v bytes long", row, len(ct))
+	}
+	return s.Keystream.AddCrib(CribMatch{
+		Crib:       text,
+		Ciphertext: row,
+		Offset:     col,
+		Keystream:  XOr(ct[col:col+len(text)], text),
+	}), nil
+}
+
+// Clear forgets n keystream bytes starting at column col.
+func (s *FixedNonceSession) Clear(col, n int) {
+	for i := col; i < col+n && i < len(s.Keystream.Known); i++ {
+		if i < 0 {
+			continue
+		}
+		s.Keystream.Known[i] = false
+		s.Keystream.Bytes[i] = 0
+		s.Keystream.Provenance[i] = KeystreamProvenance{}
+	}
+}
+
+// column returns the ciphertext bytes of every row that reaches col.
+func (s *FixedNonceSession) column(col int) []byte {
+	var out []byte
+	for _, ct := range s.Ciphertexts {
+		if col < len(ct) {
+			out = append(out, ct[col])
+		}
+	}
+	return out
+}
+
+/*
+Suggest ranks the possible keystream bytes for a column by how well scorer
+likes the resulting column of plaintext. Each candidate's Plaintext is that
+column, top to bottom, skipping rows that are too short.
+*/
+func (s *FixedNonceSession) Suggest(col int, scorer Scorer, n int) []XOrCandidate {
+	column := s.column(col)
+	if len(column) == 0 {
+		return nil
+	}
+	return SingleByteXOrCandidates(column, scorer, n)
+}
+
+/*
+AutoFill takes the top suggestion for every column that isn't known yet.
+Keystream bytes filled in this way have a provenance with Ciphertext set
+to -1, since they came from column statistics instead of a guess.
+*/
+func (s *FixedNonceSession) AutoFill(scorer Scorer) {
+	for col := 0; col < s.Width(); col++ {
+		if col < len(s.Keystream.Known) && s.Keystream.Known[col] {
+			continue
+		}
+		suggestions := s.Suggest(col, scorer, 1)
+		if len(suggestions) == 0 {
+			continue
+		}
+		s.Keystream.Set(col, suggestions[0].Key[0], KeystreamProvenance{Ciphertext: -1, Offset: col})
+	}
+}
+
+// Plaintexts decrypts every row as far as the keystream is known.
+func (s *FixedNonceSession) Plaintexts() ([][]byte, [][]bool) {
+	var pts [][]byte
+	var known [][]bool
+	for _, ct := range s.Ciphertexts {
+		pt, k := s.Keystream.Decrypt(ct)
+		pts = append(pts, pt)
+		known = append(known, k)
+	}
+	return pts, known
+}
+
+// Save writes the session to filename as JSON.
+func (s *FixedNonceSession) Save(filename string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, data, 0600)
+}
+
+// LoadFixedNonceSession reads a session written by Save.
+func LoadFixedNonceSession(filename string) (*FixedNonceSession, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	s := &FixedNonceSession{}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, err
+	}
+	if s.Keystream == nil {
+		return nil, errors.New("session has no keystream")
+	}
+	k := s.Keystream
+	if len(k.Known) != len(k.Bytes) || len(k.Provenance) != len(k.Bytes) {
+		return nil, errors.New("session keystream is inconsistent")
+	}
+	return s, nil
+}
@@ -0,0 +1,89 @@
+package cryptopals
+
+import (
+	"fmt"
+	"strings"
+)
+
+/*
+ECBCutAndPaste forges an ECB-encrypted cookie in which field is set to
+value, given only an oracle that encrypts a cookie with the attacker's
+input as one of its values, as profileFor does with the email address.
+
+template is the layout of the cookie the oracle builds - something an
+attacker can see from their own account - and inputField is the field the
+input goes into. Its value in the template is ignored. field has to come
+after inputField.
+
+DetectMode finds the block size and where the input lands, and checks them
+against the template. The forgery is then made of two pieces:
+
+  - the ciphertext of a cookie with just enough input that the value of
+    field starts a new block, cut off at that block, and
+  - the ciphertext of value with PKCS#7 padding, sent as block-aligned
+    input so it encrypts to blocks of its own.
+
+Any fields after field are lost. Value can't contain '&', '=' or '%',
+since the oracle escapes those.
+*/
+func ECBCutAndPaste(oracle func([]byte) []byte, template Cookie, inputField, field, value string) ([]byte, error) {
+	if strings.ContainsAny(value, cookieMeta) {
+		return nil, fmt.Errorf("%w: %q would be escaped", ErrInvalidCookie, value)
+	}
+
+	//split the template around the input, and find field after it
+	input := -1
+	for i, f := range template {
+		if f.Key == inputField {
+			input = i
+			break
+		}
+	}
+	if input < 0 {
+		return nil, fmt.Errorf("%w: template has no %q field", ErrInvalidCookie, inputField)
+	}
+	prefix := Cookie(template[:input+1]).Encode()
+	prefix = prefix[:len(prefix)-len(escapeCookie(template[input].Value))]
+	var suffix string
+	valueStart := -1
+	for _, f := range template[input+1:] {
+		suffix += "&" + escapeCookie(f.Key) + "="
+		if f.Key == field && valueStart < 0 {
+			valueStart = len(suffix)
+		}
+		suffix += escapeCookie(f.Value)
+	}
+	if valueStart < 0 {
+		return nil, fmt.Errorf("%w: template has no %q field after %q", ErrInvalidCookie, field, inputField)
+	}
+
+	r := DetectMode(oracle)
+	if r.Mode != ModeECB || !r.Deterministic {
+		return nil, fmt.Errorf("%w: detected %v", ErrNotECB, r)
+	}
+	if r.PrefixLength != len(prefix) || r.SuffixLength != len(suffix) {
+		return nil, fmt.Errorf("%w: template has a %d byte prefix and %d byte suffix, oracle has %d and %d",
+			ErrUnexpectedOracle, len(prefix), len(suffix), r.PrefixLength, r.SuffixLength)
+	}
+	bs := r.BlockSize
+	mod := func(n int) int { return (n%bs + bs) % bs }
+
+	//cut: everything up to the start of the value
+	n := mod(-(len(prefix) + valueStart))
+	cutEnd := len(prefix) + n + valueStart
+	ct := oracle(fill(ecbFiller, n))
+	if len(ct) < cutEnd {
+		return nil, fmt.Errorf("%w: ciphertext is too short", ErrUnexpectedOracle)
+	}
+	forged := append([]byte{}, ct[:cutEnd]...)
+
+	//paste: the new value and padding, encrypted as whole blocks
+	a := mod(-len(prefix))
+	padded := Pad([]byte(value), bs)
+	ct = oracle(append(fill(ecbFiller, a), padded...))
+	start := len(prefix) + a
+	if len(ct) < start+len(padded) {
+		return nil, fmt.Errorf("%w: ciphertext is too short", ErrUnexpectedOracle)
+	}
+	return append(forged, ct[start:start+len(padded)]...), nil
+}
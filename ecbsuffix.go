@@ -0,0 +1,383 @@
+package cryptopals
+
+import (
+	"bytes"
+	"fmt"
+)
+
+/*
+A byte-at-a-time ECB decryption engine for challenges 12 and 14, and for any
+other oracle of the form
+
+	ECB(prefix || attacker-controlled || target-bytes, key)
+
+It works out the block size, checks that the oracle is using ECB, finds
+where the attacker-controlled input lands, and then recovers the target
+bytes one at a time, stopping when it reaches the padding.
+
+The prefix can be fixed, or different on every call. Either way the engine
+finds its input by sending two different marker blocks in front of it, each
+twice: M1 M1 M2 M2. Where the markers are block aligned, that encrypts to
+two pairs of equal blocks, X X Y Y. The prefix and suffix can have repeated
+blocks of their own, so a candidate only counts once M2 M2 M1 M1 has also
+been seen to encrypt to Y Y X X. With a fixed prefix this is done once, to
+measure the prefix. With a random prefix, every query is sent behind the
+markers, and retried until the prefix happens to leave them aligned.
+*/
+
+// ECBSuffixReport is what BreakECBSuffix found out about an oracle.
+type ECBSuffixReport struct {
+	Suffix       []byte
+	BlockSize    int
+	PrefixLength int //-1 if the prefix changes from call to call
+	RandomPrefix bool
+	Queries      int
+}
+
+// maxECBBlockSize is the largest block size BreakECBSuffix looks for.
+const maxECBBlockSize = 64
+
+// DefaultECBRetries is how many times BreakECBSuffix tries each query on an
+// oracle with a random prefix before giving up.
+const DefaultECBRetries = 1000
+
+const ecbFiller = 'A'
+
+type ecbSuffixAttack struct {
+	oracle     CheckedOracle
+	report     ECBSuffixReport
+	markerCT   [2][]byte //X and Y, the ciphertexts of the two markers
+	alignment  int       //filler needed to block align our input, fixed prefix only
+	start      int       //where our input starts in the ciphertext, fixed prefix only
+	maxRetries int
+}
+
+/*
+BreakECBSuffix recovers the target bytes that oracle appends to its input
+before encrypting in ECB mode. It returns ErrNotECB if the oracle doesn't
+look like ECB, and ErrUnexpectedOracle if it stops behaving consistently.
+*/
+func BreakECBSuffix(oracle func([]byte) []byte) (ECBSuffixReport, error) {
+	return BreakECBSuffixWithRetries(oracle, DefaultECBRetries)
+}
+
+// BreakECBSuffixWithRetries is BreakECBSuffix with a limit on how many
+// times each query is retried on an oracle with a random prefix.
+func BreakECBSuffixWithRetries(oracle func([]byte) []byte, maxRetries int) (ECBSuffixReport, error) {
+	return BreakECBSuffixChecked(UncheckedOracle(oracle), maxRetries)
+}
+
+// BreakECBSuffixChecked is BreakECBSuffixWithRetries for an oracle that
+// can fail. The first error from the oracle stops the attack and is
+// returned as it is.
+func BreakECBSuffixChecked(oracle CheckedOracle, maxRetries int) (ECBSuffixReport, error) {
+	a := &ecbSuffixAttack{oracle: oracle, maxRetries: maxRetries}
+	if err := a.findBlockSize(); err != nil {
+		return a.report, err
+	}
+	if err := a.checkECB(); err != nil {
+		return a.report, err
+	}
+	if err := a.findMarker(); err != nil {
+		return a.report, err
+	}
+	err := a.recoverSuffix()
+	return a.report, err
+}
+
+func (a *ecbSuffixAttack) query(input []byte) ([]byte, error) {
+	a.report.Queries++
+	return a.oracle(input)
+}
+
+func fill(b byte, n int) []byte {
+	return bytes.Repeat([]byte{b}, n)
+}
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+/*
+findBlockSize takes the GCD of the ciphertext lengths for inputs of every
+length up to twice the largest block size. With a fixed prefix the length
+goes up by exactly one block somewhere in that range; with a random one,
+the lengths vary enough anyway.
+*/
+func (a *ecbSuffixAttack) findBlockSize() error {
+	bs := 0
+	for n := 0; n <= 2*maxECBBlockSize; n++ {
+		ct, err := a.query(fill(ecbFiller, n))
+		if err != nil {
+			return err
+		}
+		bs = gcd(bs, len(ct))
+	}
+	if bs < 2 || bs > maxECBBlockSize {
+		return fmt.Errorf("%w: ciphertext lengths give a block size of %d", ErrUnexpectedOracle, bs)
+	}
+	a.report.BlockSize = bs
+	return nil
+}
+
+// findAdjacent returns the index of the first block of ct that is equal to
+// the block after it, and -1 if there isn't one. If want isn't nil, only
+// pairs equal to want count.
+func findAdjacent(ct []byte, bs int, want []byte) int {
+	for i := 0; i+2*bs <= len(ct); i += bs {
+		block := ct[i : i+bs]
+		if bytes.Equal(block, ct[i+bs:i+2*bs]) && (want == nil || bytes.Equal(block, want)) {
+			return i
+		}
+	}
+	return -1
+}
+
+/*
+markers returns the two marker blocks. The bytes of each are all different,
+and differ from the other marker's in every position, so no pair of
+blocks that isn't an aligned marker pair can be equal because of the
+markers alone.
+*/
+func (a *ecbSuffixAttack) markers() ([]byte, []byte) {
+	m1, m2 := make([]byte, a.report.BlockSize), make([]byte, a.report.BlockSize)
+	for i := range m1 {
+		m1[i] = byte(0x80 + i)
+		m2[i] = byte(0xc0 + i)
+	}
+	return m1, m2
+}
+
+// markerInput returns first first second second, followed by input.
+func markerInput(first, second, input []byte) []byte {
+	out := append(append([]byte{}, first...), first...)
+	out = append(append(out, second...), second...)
+	return append(out, input...)
+}
+
+// markerPatterns returns the indexes of ct where there's a pair of equal
+// blocks followed by a pair of different equal blocks.
+func markerPatterns(ct []byte, bs int) []int {
+	var found []int
+	for i := 0; i+4*bs <= len(ct); i += bs {
+		x, y := ct[i:i+bs], ct[i+2*bs:i+3*bs]
+		if bytes.Equal(x, ct[i+bs:i+2*bs]) && bytes.Equal(y, ct[i+3*bs:i+4*bs]) && !bytes.Equal(x, y) {
+			found = append(found, i)
+		}
+	}
+	return found
+}
+
+// findPattern returns the index of the first x x y y in ct, or -1.
+func findPattern(ct []byte, bs int, x, y []byte) int {
+	for _, i := range markerPatterns(ct, bs) {
+		if bytes.Equal(ct[i:i+bs], x) && bytes.Equal(ct[i+2*bs:i+3*bs], y) {
+			return i
+		}
+	}
+	return -1
+}
+
+// ecbPrefixChecks is how many times checkECB sends the same input to tell a
+// fixed prefix from a random one.
+const ecbPrefixChecks = 4
+
+/*
+checkECB checks that three blocks' worth of filler encrypts to at least one
+pair of equal blocks, which it always does under ECB whatever the prefix.
+Sending the same thing a few times tells a fixed prefix from a random one,
+even one that only comes in a few lengths.
+*/
+func (a *ecbSuffixAttack) checkECB() error {
+	input := fill(ecbFiller, 3*a.report.BlockSize)
+	ct, err := a.query(input)
+	if err != nil {
+		return err
+	}
+	if findAdjacent(ct, a.report.BlockSize, nil) < 0 {
+		return ErrNotECB
+	}
+	a.report.PrefixLength = -1
+	for i := 1; i < ecbPrefixChecks && !a.report.RandomPrefix; i++ {
+		again, err := a.query(input)
+		if err != nil {
+			return err
+		}
+		a.report.RandomPrefix = !bytes.Equal(ct, again)
+	}
+	return nil
+}
+
+/*
+findMarker learns the ciphertexts of the markers. With a fixed prefix, the
+least filler that block aligns them also gives the prefix length: the
+markers in both orders have to show up at the same place, with X and Y
+swapped. With a random prefix, the markers are sent in each order in turn
+until X X Y Y has been seen twice with one order and Y Y X X twice with the
+other. Patterns that come from the prefix or suffix don't depend on the
+order, so any that also turn up the other way round are ignored.
+*/
+func (a *ecbSuffixAttack) findMarker() error {
+	bs := a.report.BlockSize
+	m1, m2 := a.markers()
+	if a.report.RandomPrefix {
+		//how often each X X Y Y has been seen, for each order the markers
+		//were sent in
+		seen := [2]map[string]int{{}, {}}
+		for i := 0; i < a.maxRetries; i++ {
+			order := i % 2
+			input := markerInput(m1, m2, nil)
+			if order == 1 {
+				input = markerInput(m2, m1, nil)
+			}
+			ct, err := a.query(input)
+			if err != nil {
+				return err
+			}
+			for _, j := range markerPatterns(ct, bs) {
+				seen[order][string(ct[j:j+bs])+string(ct[j+2*bs:j+3*bs])]++
+			}
+			for xy, n := range seen[0] {
+				yx := xy[bs:] + xy[:bs]
+				if n >= 2 && seen[1][yx] >= 2 && seen[1][xy] == 0 && seen[0][yx] == 0 {
+					a.markerCT = [2][]byte{[]byte(xy[:bs]), []byte(xy[bs:])}
+					return nil
+				}
+			}
+		}
+		return fmt.Errorf("%w: markers weren't block aligned in %d tries", ErrUnexpectedOracle, a.maxRetries)
+	}
+	for k := 0; k < bs; k++ {
+		filler := fill(ecbFiller, k)
+		ct, err := a.query(append(filler, markerInput(m1, m2, nil)...))
+		if err != nil {
+			return err
+		}
+		swapped, err := a.query(append(filler, markerInput(m2, m1, nil)...))
+		if err != nil {
+			return err
+		}
+		for _, i := range markerPatterns(ct, bs) {
+			x, y := ct[i:i+bs], ct[i+2*bs:i+3*bs]
+			if len(swapped) < i+4*bs || findPattern(swapped[i:i+4*bs], bs, y, x) != 0 {
+				continue
+			}
+			a.markerCT = [2][]byte{x, y}
+			a.alignment = k
+			a.start = i
+			a.report.PrefixLength = i - k
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: couldn't block align the input", ErrUnexpectedOracle)
+}
+
+// aligned encrypts input so that it starts on a block boundary, and returns
+// the ciphertext from the start of input on.
+func (a *ecbSuffixAttack) aligned(input []byte) ([]byte, error) {
+	bs := a.report.BlockSize
+	if !a.report.RandomPrefix {
+		ct, err := a.query(append(fill(ecbFiller, a.alignment), input...))
+		if err != nil {
+			return nil, err
+		}
+		if len(ct) < a.start {
+			return nil, fmt.Errorf("%w: ciphertext got shorter", ErrUnexpectedOracle)
+		}
+		return ct[a.start:], nil
+	}
+	m1, m2 := a.markers()
+	marked := markerInput(m1, m2, input)
+	for i := 0; i < a.maxRetries; i++ {
+		ct, err := a.query(marked)
+		if err != nil {
+			return nil, err
+		}
+		if j := findPattern(ct, bs, a.markerCT[0], a.markerCT[1]); j >= 0 {
+			return ct[j+4*bs:], nil
+		}
+	}
+	return nil, fmt.Errorf("%w: input wasn't block aligned in %d tries", ErrUnexpectedOracle, a.maxRetries)
+}
+
+/*
+suffixLength finds the smallest amount of filler n that makes the
+ciphertext a block longer. At that point filler and suffix exactly fill the
+blocks before the one that is all padding, so the suffix is as long as the
+original ciphertext less n.
+*/
+func (a *ecbSuffixAttack) suffixLength() (int, error) {
+	ct, err := a.aligned(nil)
+	if err != nil {
+		return 0, err
+	}
+	base := len(ct)
+	for n := 1; n <= a.report.BlockSize; n++ {
+		ct, err := a.aligned(fill(ecbFiller, n))
+		if err != nil {
+			return 0, err
+		}
+		if len(ct) > base {
+			return base - n, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: ciphertext length never changed", ErrUnexpectedOracle)
+}
+
+/*
+recoverSuffix finds each byte by lining it up as the last byte of a block
+whose other bytes are already known, then matching that block against the
+encryptions of all 256 possibilities. The dictionary is built with a single
+query, by sending all 256 candidate blocks together. The ciphertexts with
+each length of filler are cached, since every bs-th byte uses the same one.
+*/
+func (a *ecbSuffixAttack) recoverSuffix() error {
+	bs := a.report.BlockSize
+	length, err := a.suffixLength()
+	if err != nil {
+		return err
+	}
+
+	targets := make(map[int][]byte)
+	known := fill(ecbFiller, bs-1)
+	for i := 0; i < length; i++ {
+		f := bs - 1 - i%bs
+		target, ok := targets[f]
+		if !ok {
+			if target, err = a.aligned(fill(ecbFiller, f)); err != nil {
+				return err
+			}
+			targets[f] = target
+		}
+		b := i / bs * bs
+		if len(target) < b+bs {
+			return fmt.Errorf("%w: ciphertext got shorter", ErrUnexpectedOracle)
+		}
+
+		window := known[i : i+bs-1]
+		var dictionary []byte
+		for c := 0; c < 256; c++ {
+			dictionary = append(append(dictionary, window...), byte(c))
+		}
+		ct, err := a.aligned(dictionary)
+		if err != nil {
+			return err
+		}
+		found := false
+		for c := 0; c < 256 && (c+1)*bs <= len(ct); c++ {
+			if bytes.Equal(ct[c*bs:(c+1)*bs], target[b:b+bs]) {
+				known = append(known, byte(c))
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%w: no match for byte %d", ErrUnexpectedOracle, i)
+		}
+	}
+	a.report.Suffix = known[bs-1:]
+	return nil
+}
@@ -0,0 +1,402 @@
+package cryptopals
+
+import (
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+/*
+EncryptedFile is a CTR-encrypted file that can be read and written anywhere,
+as challenge 25's "edit" function does, without decrypting the rest of it.
+It implements io.ReaderAt, io.WriterAt and io.Seeker, along with Read and
+Write at the current offset.
+
+It has two modes. An unauthenticated file is the CTR ciphertext of its
+contents under one key and nonce, so byte i is always XORed with the same
+byte of keystream. Anyone who can write to it and see the ciphertext -
+challenge 25's edit oracle - learns the keystream from their own writes,
+and with it the rest of the file. Anyone who can write to the storage can
+flip bits of the contents, too.
+
+An authenticated file is split into blocks of EncryptedFileBlockSize bytes,
+stored one after another as
+
+	IV || ciphertext || HMAC-SHA256(index || IV || ciphertext)
+
+Every write to a block encrypts it again under a fresh random IV, so
+writes tell an attacker nothing about the keystream the rest of the file
+was encrypted with, and every read checks the block's MAC. The index in the
+MAC stops blocks being moved around. Cutting blocks off the end of the file
+isn't detected.
+
+ReadAt and WriteAt are safe to call from several goroutines at once; a
+write to an authenticated block rewrites the whole block, so writes are
+done one at a time. Read, Write and Seek share an offset, and aren't.
+*/
+type EncryptedFile struct {
+	storage EncryptedStorage
+	block   cipher.Block
+	//nonce is only used by unauthenticated files, macKey only by
+	//authenticated ones
+	nonce  []byte
+	macKey []byte
+	//mu guards size and the storage
+	mu     sync.RWMutex
+	size   int64
+	offset int64
+}
+
+// EncryptedStorage is where an EncryptedFile keeps its ciphertext. An
+// *os.File will do.
+type EncryptedStorage interface {
+	io.ReaderAt
+	io.WriterAt
+}
+
+// EncryptedFileBlockSize is how much of an authenticated file each IV and
+// MAC covers.
+const EncryptedFileBlockSize = 4096
+
+const (
+	encryptedFileIVSize  = 16
+	encryptedFileMACSize = sha256.Size
+	encryptedFileRecord  = encryptedFileIVSize + EncryptedFileBlockSize + encryptedFileMACSize
+)
+
+/*
+NewEncryptedFile opens an unauthenticated file whose storage holds size
+bytes of ciphertext, encrypted with AES in CTR mode with challenge 18's
+counter layout: an 8-byte nonce and a 64-bit little-endian block counter.
+*/
+func NewEncryptedFile(storage EncryptedStorage, size int64, key, nonce []byte) (*EncryptedFile, error) {
+	block, err := newAESCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != 8 {
+		return nil, fmt.Errorf("%w: %d bytes, expected 8", ErrInvalidNonceSize, len(nonce))
+	}
+	if size < 0 {
+		return nil, fmt.Errorf("%w: %d byte file", ErrInvalidLength, size)
+	}
+	return &EncryptedFile{storage: storage, block: block, nonce: append([]byte{}, nonce...), size: size}, nil
+}
+
+/*
+NewAuthenticatedEncryptedFile opens an authenticated file whose storage
+holds size bytes of IVs, ciphertext and MACs. key is the AES key and macKey
+the HMAC key; they should be different.
+*/
+func NewAuthenticatedEncryptedFile(storage EncryptedStorage, size int64, key, macKey []byte) (*EncryptedFile, error) {
+	block, err := newAESCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(macKey) == 0 {
+		return nil, fmt.Errorf("%w: empty MAC key", ErrInvalidKeySize)
+	}
+	full, rest := size/encryptedFileRecord, size%encryptedFileRecord
+	if size < 0 || rest != 0 && rest <= encryptedFileIVSize+encryptedFileMACSize {
+		return nil, fmt.Errorf("%w: %d bytes of storage isn't a whole number of blocks", ErrInvalidLength, size)
+	}
+	plain := full * EncryptedFileBlockSize
+	if rest != 0 {
+		plain += rest - encryptedFileIVSize - encryptedFileMACSize
+	}
+	return &EncryptedFile{storage: storage, block: block, macKey: append([]byte{}, macKey...), size: plain}, nil
+}
+
+// OpenEncryptedFile is NewEncryptedFile for a file on disk.
+func OpenEncryptedFile(f *os.File, key, nonce []byte) (*EncryptedFile, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return NewEncryptedFile(f, info.Size(), key, nonce)
+}
+
+// OpenAuthenticatedEncryptedFile is NewAuthenticatedEncryptedFile for a
+// file on disk.
+func OpenAuthenticatedEncryptedFile(f *os.File, key, macKey []byte) (*EncryptedFile, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return NewAuthenticatedEncryptedFile(f, info.Size(), key, macKey)
+}
+
+// Size returns the length of the file's contents.
+func (f *EncryptedFile) Size() int64 {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.size
+}
+
+// Authenticated reports whether the file has per-block MACs.
+func (f *EncryptedFile) Authenticated() bool {
+	return f.macKey != nil
+}
+
+// keystream XORs buf with the keystream of an unauthenticated file,
+// starting at offset off. Only the blocks buf covers are computed.
+func (f *EncryptedFile) keystream(buf []byte, off int64) error {
+	ctr, err := NewCTRWithNonce(f.block, f.nonce, uint64(off/16), CTRLayoutChallenge18)
+	if err != nil {
+		return err
+	}
+	skip := make([]byte, off%16)
+	ctr.XORKeyStream(skip, skip)
+	return ctr.XORKeyStreamChecked(buf, buf)
+}
+
+// readFull reads exactly len(p) bytes of storage, or fails.
+func (f *EncryptedFile) readFull(p []byte, off int64) error {
+	n, err := f.storage.ReadAt(p, off)
+	if n == len(p) {
+		return nil
+	}
+	if err == nil || err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+func (f *EncryptedFile) blockMAC(index int64, iv, ct []byte) []byte {
+	mac := hmac.New(sha256.New, f.macKey)
+	var i [8]byte
+	binary.BigEndian.PutUint64(i[:], uint64(index))
+	mac.Write(i[:])
+	mac.Write(iv)
+	mac.Write(ct)
+	return mac.Sum(nil)
+}
+
+// blockCTR XORs buf with the keystream an authenticated block's IV starts.
+func (f *EncryptedFile) blockCTR(iv, buf []byte) error {
+	ctr, err := NewCTR(f.block, iv, CTRLayoutNIST)
+	if err != nil {
+		return err
+	}
+	return ctr.XORKeyStreamChecked(buf, buf)
+}
+
+// blockLength returns the length of the contents of block index of an
+// authenticated file.
+func (f *EncryptedFile) blockLength(index int64) int {
+	start := index * EncryptedFileBlockSize
+	if f.size-start >= EncryptedFileBlockSize {
+		return EncryptedFileBlockSize
+	}
+	return int(f.size - start)
+}
+
+// readBlock returns the contents of block index of an authenticated file,
+// or an error wrapping ErrAuthenticationFailed if its MAC is wrong.
+func (f *EncryptedFile) readBlock(index int64) ([]byte, error) {
+	n := f.blockLength(index)
+	record := make([]byte, encryptedFileIVSize+n+encryptedFileMACSize)
+	if err := f.readFull(record, index*encryptedFileRecord); err != nil {
+		return nil, err
+	}
+	iv, ct, tag := record[:encryptedFileIVSize], record[encryptedFileIVSize:encryptedFileIVSize+n], record[encryptedFileIVSize+n:]
+	if !hmac.Equal(tag, f.blockMAC(index, iv, ct)) {
+		return nil, fmt.Errorf("%w: block %d", ErrAuthenticationFailed, index)
+	}
+	if err := f.blockCTR(iv, ct); err != nil {
+		return nil, err
+	}
+	return ct, nil
+}
+
+// writeBlock encrypts pt as block index of an authenticated file, under a
+// new IV.
+func (f *EncryptedFile) writeBlock(index int64, pt []byte) error {
+	iv, err := KeyChecked(encryptedFileIVSize)
+	if err != nil {
+		return err
+	}
+	ct := append([]byte{}, pt...)
+	if err := f.blockCTR(iv, ct); err != nil {
+		return err
+	}
+	record := append(append(iv, ct...), f.blockMAC(index, iv, ct)...)
+	_, err = f.storage.WriteAt(record, index*encryptedFileRecord)
+	return err
+}
+
+// ReadAt reads len(p) bytes of the contents from offset off. Like any
+// io.ReaderAt, it returns io.EOF if there aren't that many.
+func (f *EncryptedFile) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fmt.Errorf("%w: %d", ErrInvalidOffset, off)
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if off >= f.size {
+		return 0, io.EOF
+	}
+	n := len(p)
+	if int64(n) > f.size-off {
+		n = int(f.size - off)
+	}
+
+	if !f.Authenticated() {
+		if err := f.readFull(p[:n], off); err != nil {
+			return 0, err
+		}
+		if err := f.keystream(p[:n], off); err != nil {
+			return 0, err
+		}
+	} else {
+		for done := 0; done < n; {
+			pos := off + int64(done)
+			pt, err := f.readBlock(pos / EncryptedFileBlockSize)
+			if err != nil {
+				return done, err
+			}
+			done += copy(p[done:n], pt[pos%EncryptedFileBlockSize:])
+		}
+	}
+
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+/*
+WriteAt writes p to the contents at offset off. Writing past the end of the
+file extends it, with zeros in any gap. Only the blocks p covers are
+encrypted again. If the storage fails part way, the bytes of p before the
+block that failed have been written, and their count is returned.
+*/
+func (f *EncryptedFile) WriteAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fmt.Errorf("%w: %d", ErrInvalidOffset, off)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var gap int64
+	if off > f.size {
+		gap = off - f.size
+		p = append(make([]byte, gap), p...)
+		off = f.size
+	}
+	n, err := f.writeAt(p, off)
+	if end := off + int64(n); end > f.size {
+		f.size = end
+	}
+	if int64(n) < gap {
+		return 0, err
+	}
+	return n - int(gap), err
+}
+
+// writeAt does the work of WriteAt, with off no further than the end of
+// the file. It returns how many bytes of p reached the storage.
+func (f *EncryptedFile) writeAt(p []byte, off int64) (int, error) {
+	end := off + int64(len(p))
+	if !f.Authenticated() {
+		ct := append([]byte{}, p...)
+		if err := f.keystream(ct, off); err != nil {
+			return 0, err
+		}
+		return f.storage.WriteAt(ct, off)
+	}
+	for index := off / EncryptedFileBlockSize; index*EncryptedFileBlockSize < end; index++ {
+		start := index * EncryptedFileBlockSize
+		//everything before this block is already written
+		done := 0
+		if start > off {
+			done = int(start - off)
+		}
+		var pt []byte
+		if start < f.size {
+			var err error
+			if pt, err = f.readBlock(index); err != nil {
+				return done, err
+			}
+		}
+		blockEnd := start + EncryptedFileBlockSize
+		if blockEnd > end {
+			blockEnd = end
+		}
+		if n := int(blockEnd - start); n > len(pt) {
+			pt = append(pt, make([]byte, n-len(pt))...)
+		}
+		from := off
+		if from < start {
+			from = start
+		}
+		copy(pt[from-start:], p[from-off:blockEnd-off])
+		if err := f.writeBlock(index, pt); err != nil {
+			return done, err
+		}
+	}
+	return len(p), nil
+}
+
+// Seek sets the offset for the next Read or Write. Seeking past the end is
+// allowed; writing there extends the file.
+func (f *EncryptedFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += f.Size()
+	default:
+		return 0, fmt.Errorf("%w: whence %d", ErrInvalidOffset, whence)
+	}
+	if offset < 0 {
+		return 0, fmt.Errorf("%w: %d", ErrInvalidOffset, offset)
+	}
+	f.offset = offset
+	return offset, nil
+}
+
+// Read reads from the current offset.
+func (f *EncryptedFile) Read(p []byte) (int, error) {
+	n, err := f.ReadAt(p, f.offset)
+	f.offset += int64(n)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+// Write writes at the current offset.
+func (f *EncryptedFile) Write(p []byte) (int, error) {
+	n, err := f.WriteAt(p, f.offset)
+	f.offset += int64(n)
+	return n, err
+}
+
+// memoryStorage is EncryptedStorage in memory.
+type memoryStorage struct {
+	data []byte
+}
+
+func (m *memoryStorage) ReadAt(p []byte, off int64) (int, error) {
+	if off >= int64(len(m.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, m.data[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (m *memoryStorage) WriteAt(p []byte, off int64) (int, error) {
+	if end := off + int64(len(p)); end > int64(len(m.data)) {
+		m.data = append(m.data, make([]byte, end-int64(len(m.data)))...)
+	}
+	return copy(m.data[off:], p), nil
+}
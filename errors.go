@@ -0,0 +1,50 @@
+package cryptopals
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+	"fmt"
+)
+
+/*
+Errors returned by the Checked variants of the cipher primitives and the
+other error-returning APIs. The original functions panic on the same
+conditions; the Checked ones wrap one of these, so callers can test for
+them with errors.Is.
+*/
+var (
+	ErrNotBlockAligned      = errors.New("length isn't a multiple of the block size")
+	ErrLengthMismatch       = errors.New("buffers are not the same length")
+	ErrInvalidKeySize       = errors.New("invalid key size")
+	ErrInvalidIVSize        = errors.New("IV length doesn't match the block size")
+	ErrInvalidNonceSize     = errors.New("invalid nonce size")
+	ErrInvalidBlockSize     = errors.New("block size needs to be a positive number")
+	ErrInvalidOffset        = errors.New("invalid offset")
+	ErrInvalidLength        = errors.New("length needs to be a positive number")
+	ErrInvalidCounter       = errors.New("invalid CTR counter layout")
+	ErrCounterWrapped       = errors.New("CTR counter wrapped around")
+	ErrUnsupportedBlockSize = errors.New("unsupported block size")
+	ErrInvalidTagSize       = errors.New("invalid tag size")
+	ErrInvalidPadding       = errors.New("invalid padding")
+	ErrPaddingOracleFailed  = errors.New("padding oracle attack failed")
+	ErrNotECB               = errors.New("oracle doesn't look like ECB")
+	ErrUnexpectedOracle     = errors.New("oracle behaved unexpectedly")
+	ErrQueryBudgetExceeded  = errors.New("oracle query budget exceeded")
+	ErrNotInTranscript      = errors.New("query isn't in the transcript")
+	ErrInvalidCookie        = errors.New("invalid cookie")
+	ErrCannotFlip           = errors.New("target can't be made by flipping bits")
+	ErrNonASCII             = errors.New("message isn't ASCII")
+	ErrKeyRecoveryFailed    = errors.New("key recovery failed")
+	ErrAuthenticationFailed = errors.New("message authentication failed")
+)
+
+// newAESCipher is aes.NewCipher, with key size errors wrapping
+// ErrInvalidKeySize.
+func newAESCipher(key []byte) (cipher.Block, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %d bytes", ErrInvalidKeySize, len(key))
+	}
+	return block, nil
+}
@@ -0,0 +1,219 @@
+package cryptopals
+
+import (
+	"crypto/subtle"
+	"encoding/binary"
+	"fmt"
+)
+
+/*
+AES-GCM, written by hand on top of AESInECBModeEncrypt, following NIST
+SP 800-38D. The pieces that the standard library keeps to itself - the
+GF(2^128) arithmetic, GHASH, the hash key H and the counter blocks - are
+all exported, since attacks on GCM (nonce reuse, short tags) are attacks
+on exactly those pieces.
+*/
+
+/*
+GF128 is an element of GF(2^128) as GCM uses it. A 16-byte block maps to
+a polynomial with the most significant bit of the first byte as the
+coefficient of x^0, so Hi holds the first 8 bytes big-endian and Lo the
+last 8. The field is reduced by x^128 + x^7 + x^2 + x + 1.
+*/
+type GF128 struct {
+	Hi, Lo uint64
+}
+
+// GF128FromBytes reads a 16-byte block as a field element.
+func GF128FromBytes(b []byte) GF128 {
+	return GF128{binary.BigEndian.Uint64(b[:8]), binary.BigEndian.Uint64(b[8:16])}
+}
+
+// Bytes returns the 16-byte block for x.
+func (x GF128) Bytes() []byte {
+	out := make([]byte, 16)
+	binary.BigEndian.PutUint64(out[:8], x.Hi)
+	binary.BigEndian.PutUint64(out[8:], x.Lo)
+	return out
+}
+
+// Add returns x + y, which in a binary field is XOR. Subtraction is the
+// same thing.
+func (x GF128) Add(y GF128) GF128 {
+	return GF128{x.Hi ^ y.Hi, x.Lo ^ y.Lo}
+}
+
+// Mul returns x * y, using algorithm 1 from SP 800-38D.
+func (x GF128) Mul(y GF128) GF128 {
+	var z GF128
+	v := y
+	for i := 0; i < 128; i++ {
+		var bit uint64
+		if i < 64 {
+			bit = x.Hi >> (63 - i) & 1
+		} else {
+			bit = x.Lo >> (127 - i) & 1
+		}
+		if bit == 1 {
+			z = z.Add(v)
+		}
+		//multiply v by x: shift towards the high-degree end and reduce
+		carry := v.Lo & 1
+		v.Lo = v.Lo>>1 | v.Hi<<63
+		v.Hi >>= 1
+		if carry == 1 {
+			v.Hi ^= 0xe1 << 56
+		}
+	}
+	return z
+}
+
+// IsZero reports whether x is the zero element.
+func (x GF128) IsZero() bool {
+	return x.Hi == 0 && x.Lo == 0
+}
+
+/*
+GHASHBlocks returns the field elements that GHASH works through for the
+given additional data and ciphertext: each zero-padded to a whole number
+of blocks, followed by a block holding both bit lengths. GHASH is then
+the polynomial with these as coefficients evaluated at H, which is the
+view the nonce-reuse attack takes.
+*/
+func GHASHBlocks(aad, ciphertext []byte) []GF128 {
+	var blocks []GF128
+	for _, data := range [][]byte{aad, ciphertext} {
+		for i := 0; i < len(data); i += 16 {
+			block := make([]byte, 16)
+			copy(block, data[i:])
+			blocks = append(blocks, GF128FromBytes(block))
+		}
+	}
+	lengths := GF128{uint64(len(aad)) * 8, uint64(len(ciphertext)) * 8}
+	return append(blocks, lengths)
+}
+
+// GHASH computes GHASH_H over the additional data and ciphertext.
+func GHASH(h GF128, aad, ciphertext []byte) GF128 {
+	var y GF128
+	for _, block := range GHASHBlocks(aad, ciphertext) {
+		y = y.Add(block).Mul(h)
+	}
+	return y
+}
+
+// GCMInc32 returns a copy of a counter block with its last 32 bits
+// incremented, wrapping around at 2^32.
+func GCMInc32(block []byte) []byte {
+	out := make([]byte, 16)
+	copy(out, block)
+	binary.BigEndian.PutUint32(out[12:], binary.BigEndian.Uint32(out[12:])+1)
+	return out
+}
+
+/*
+GCMPreCounterBlock computes J0 from the nonce. A 12-byte nonce is used
+directly with a counter of 1; any other length is run through GHASH.
+*/
+func GCMPreCounterBlock(h GF128, nonce []byte) []byte {
+	if len(nonce) == 12 {
+		j0 := make([]byte, 16)
+		copy(j0, nonce)
+		j0[15] = 1
+		return j0
+	}
+	return GHASH(h, nil, nonce).Bytes()
+}
+
+// GCMCounterBlocks returns the n counter blocks used to encrypt a message,
+// starting at inc32(J0). J0 itself is saved for masking the tag.
+func GCMCounterBlocks(j0 []byte, n int) [][]byte {
+	var blocks [][]byte
+	cb := j0
+	for i := 0; i < n; i++ {
+		cb = GCMInc32(cb)
+		blocks = append(blocks, cb)
+	}
+	return blocks
+}
+
+// AESGCM is an AEAD built from the pieces above.
+type AESGCM struct {
+	key     []byte
+	h       GF128
+	tagSize int
+}
+
+// NewAESGCM creates AES-GCM with full 16-byte tags.
+func NewAESGCM(key []byte) (*AESGCM, error) {
+	return NewAESGCMWithTagSize(key, 16)
+}
+
+/*
+NewAESGCMWithTagSize creates AES-GCM with tags truncated to tagSize bytes.
+SP 800-38D allows 4 to 16 bytes; anything under 12 is only for attacking.
+*/
+func NewAESGCMWithTagSize(key []byte, tagSize int) (*AESGCM, error) {
+	if tagSize < 4 || tagSize > 16 {
+		return nil, fmt.Errorf("%w: %d bytes", ErrInvalidTagSize, tagSize)
+	}
+	h, err := AESInECBModeEncryptChecked(make([]byte, 16), key)
+	if err != nil {
+		return nil, err
+	}
+	k := make([]byte, len(key))
+	copy(k, key)
+	return &AESGCM{k, GF128FromBytes(h), tagSize}, nil
+}
+
+// H returns the GHASH key, E(K, 0^128).
+func (g *AESGCM) H() GF128 {
+	return g.h
+}
+
+// TagSize returns the tag length in bytes.
+func (g *AESGCM) TagSize() int {
+	return g.tagSize
+}
+
+// gctr encrypts or decrypts data with the counter blocks that follow j0.
+func (g *AESGCM) gctr(j0, data []byte) []byte {
+	n := (len(data) + 15) / 16
+	keystream := AESInECBModeEncrypt(Flatten(GCMCounterBlocks(j0, n)), g.key)
+	return XOr(data, keystream[:len(data)])
+}
+
+// Tag computes the (truncated) authentication tag for a ciphertext.
+func (g *AESGCM) Tag(nonce, ciphertext, aad []byte) []byte {
+	j0 := GCMPreCounterBlock(g.h, nonce)
+	s := GHASH(g.h, aad, ciphertext).Bytes()
+	tag := XOr(AESInECBModeEncrypt(j0, g.key), s)
+	return tag[:g.tagSize]
+}
+
+// Seal encrypts and authenticates plaintext and aad, and returns the
+// ciphertext with the tag appended.
+func (g *AESGCM) Seal(nonce, plaintext, aad []byte) ([]byte, error) {
+	if len(nonce) == 0 {
+		return nil, fmt.Errorf("%w: GCM needs a nonce", ErrInvalidNonceSize)
+	}
+	ct := g.gctr(GCMPreCounterBlock(g.h, nonce), plaintext)
+	return append(ct, g.Tag(nonce, ct, aad)...), nil
+}
+
+// Open checks the tag on a ciphertext from Seal and decrypts it. It
+// returns ErrAuthenticationFailed if the tag doesn't match.
+func (g *AESGCM) Open(nonce, sealed, aad []byte) ([]byte, error) {
+	if len(nonce) == 0 {
+		return nil, fmt.Errorf("%w: GCM needs a nonce", ErrInvalidNonceSize)
+	}
+	if len(sealed) < g.tagSize {
+		return nil, ErrAuthenticationFailed
+	}
+	ct := sealed[:len(sealed)-g.tagSize]
+	tag := sealed[len(sealed)-g.tagSize:]
+	if subtle.ConstantTimeCompare(tag, g.Tag(nonce, ct, aad)) != 1 {
+		return nil, ErrAuthenticationFailed
+	}
+	return g.gctr(GCMPreCounterBlock(g.h, nonce), ct), nil
+}
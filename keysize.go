@@ -0,0 +1,199 @@
+package cryptopals
+
+import (
+	"math"
+	"sort"
+)
+
+// KeySizeGuess is a candidate repeating-key XOR key length. The confidences
+// of all the guesses returned by one estimator add up to 1.
+type KeySizeGuess struct {
+	KeySize    int
+	Confidence float64
+}
+
+/*
+KeySizeEstimator ranks the key lengths from minSize to maxSize (inclusive)
+for a repeating-key XOR ciphertext, most likely first. Key lengths that the
+ciphertext is too short to say anything about are left out.
+*/
+type KeySizeEstimator func(ct []byte, minSize, maxSize int) []KeySizeGuess
+
+// rankKeySizes turns per-size goodness values (higher is better, 0 means
+// "looks random") into guesses sorted by confidence.
+func rankKeySizes(sizes []int, goodness []float64) []KeySizeGuess {
+	var total float64
+	for _, g := range goodness {
+		total += g
+	}
+	guesses := make([]KeySizeGuess, len(sizes))
+	for i, size := range sizes {
+		confidence := 1 / float64(len(sizes))
+		if total > 0 {
+			confidence = goodness[i] / total
+		}
+		guesses[i] = KeySizeGuess{size, confidence}
+	}
+	sort.SliceStable(guesses, func(i, j int) bool {
+		return guesses[i].Confidence > guesses[j].Confidence
+	})
+	return guesses
+}
+
+// hammingMaxBlocks is how many blocks HammingKeySizes compares at most.
+const hammingMaxBlocks = 64
+
+/*
+HammingKeySizes averages the normalized Hamming distance over every pair of
+the first hammingMaxBlocks keySize-length blocks. Two independent random
+bytes differ in 4 bits on average, so each size is rated by how far below 4
+bits per byte it gets. Every size needs at least two full blocks. Capping
+the blocks keeps the cost the same however long the ciphertext is.
+*/
+func HammingKeySizes(ct []byte, minSize, maxSize int) []KeySizeGuess {
+	if minSize < 1 {
+		minSize = 1
+	}
+	var sizes []int
+	var goodness []float64
+	for size := minSize; size <= maxSize && 2*size <= len(ct); size++ {
+		blocks := len(ct) / size
+		if blocks > hammingMaxBlocks {
+			blocks = hammingMaxBlocks
+		}
+		var total float64
+		pairs := 0
+		for i := 0; i < blocks; i++ {
+			for j := i + 1; j < blocks; j++ {
+				b1 := ct[i*size : (i+1)*size]
+				b2 := ct[j*size : (j+1)*size]
+				total += float64(HammingDistance(b1, b2)) / float64(size)
+				pairs++
+			}
+		}
+		sizes = append(sizes, size)
+		goodness = append(goodness, math.Max(0, 4-total/float64(pairs)))
+	}
+	return rankKeySizes(sizes, goodness)
+}
+
+// indexOfCoincidence is the chance that two bytes picked at random from b
+// are equal.
+func indexOfCoincidence(b []byte) float64 {
+	if len(b) < 2 {
+		return 0
+	}
+	var counts [256]int
+	for _, c := range b {
+		counts[c]++
+	}
+	var sum float64
+	for _, n := range counts {
+		sum += float64(n * (n - 1))
+	}
+	return sum / float64(len(b)*(len(b)-1))
+}
+
+/*
+IndexOfCoincidenceKeySizes transposes the ciphertext for every key size and
+averages the index of coincidence of the columns. With the right size each
+column is a single-byte XOR of plaintext and keeps the plaintext's IoC;
+otherwise the columns look closer to random bytes (1/256). Every size needs
+at least two bytes per column.
+*/
+func IndexOfCoincidenceKeySizes(ct []byte, minSize, maxSize int) []KeySizeGuess {
+	if minSize < 1 {
+		minSize = 1
+	}
+	var sizes []int
+	var goodness []float64
+	for size := minSize; size <= maxSize && 2*size <= len(ct); size++ {
+		var total float64
+		for _, column := range transposeBlocks(ct, size) {
+			total += indexOfCoincidence(column)
+		}
+		sizes = append(sizes, size)
+		goodness = append(goodness, math.Max(0, total/float64(size)-1.0/256))
+	}
+	return rankKeySizes(sizes, goodness)
+}
+
+/*
+KasiskiKeySizes looks for repeated trigrams in the ciphertext. Repeats that
+come from the same plaintext under the same part of the key are a multiple
+of the key length apart. Each size is rated by how much more often it
+divides those distances than it would divide random ones (1/size of the
+time), so multiples of the key length don't beat the key length itself.
+*/
+func KasiskiKeySizes(ct []byte, minSize, maxSize int) []KeySizeGuess {
+	if minSize < 1 {
+		minSize = 1
+	}
+	lastSeen := make(map[string]int)
+	var distances []int
+	for i := 0; i+3 <= len(ct); i++ {
+		trigram := string(ct[i : i+3])
+		if j, ok := lastSeen[trigram]; ok {
+			distances = append(distances, i-j)
+		}
+		lastSeen[trigram] = i
+	}
+
+	var sizes []int
+	var goodness []float64
+	for size := minSize; size <= maxSize && size <= len(ct); size++ {
+		g := 0.0
+		if len(distances) > 0 {
+			divides := 0
+			for _, d := range distances {
+				if d%size == 0 {
+					divides++
+				}
+			}
+			g = math.Max(0, float64(divides)/float64(len(distances))-1/float64(size))
+		}
+		sizes = append(sizes, size)
+		goodness = append(goodness, g)
+	}
+	return rankKeySizes(sizes, goodness)
+}
+
+/*
+FriedmanKeySizes uses Friedman's kappa test: for each key size, the rate at
+which the ciphertext matches itself shifted by that many bytes. Bytes a key
+length apart were XORed with the same key byte, so they match as often as
+plaintext bytes do; other shifts match less often. How much less depends on
+the key - an all-letter key makes different columns collide far more often
+than 1/256 - so the background rate is the median over all the sizes tried,
+and each size is rated by how far above it the size gets. Every size needs
+at least two full blocks.
+*/
+func FriedmanKeySizes(ct []byte, minSize, maxSize int) []KeySizeGuess {
+	if minSize < 1 {
+		minSize = 1
+	}
+	var sizes []int
+	var kappas []float64
+	for size := minSize; size <= maxSize && 2*size <= len(ct); size++ {
+		same := 0
+		for i := 0; i+size < len(ct); i++ {
+			if ct[i] == ct[i+size] {
+				same++
+			}
+		}
+		sizes = append(sizes, size)
+		kappas = append(kappas, float64(same)/float64(len(ct)-size))
+	}
+	if len(kappas) == 0 {
+		return nil
+	}
+
+	sorted := append([]float64{}, kappas...)
+	sort.Float64s(sorted)
+	background := sorted[len(sorted)/2]
+	goodness := make([]float64, len(kappas))
+	for i, k := range kappas {
+		goodness[i] = math.Max(0, k-background)
+	}
+	return rankKeySizes(sizes, goodness)
+}
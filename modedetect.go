@@ -0,0 +1,250 @@
+package cryptopals
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// CipherMode is a mode of operation, as told apart by DetectMode.
+type CipherMode int
+
+const (
+	ModeUnknown CipherMode = iota
+	ModeECB
+	ModeCBC
+	ModeStream
+)
+
+func (m CipherMode) String() string {
+	switch m {
+	case ModeECB:
+		return "ECB"
+	case ModeCBC:
+		return "CBC"
+	case ModeStream:
+		return "CTR/stream"
+	}
+	return "unknown"
+}
+
+/*
+ModeReport is what DetectMode found out about an encryption oracle.
+
+BlockSize is how the ciphertext length grows, so it's 1 for a stream mode
+unless the oracle pads its input to whole blocks anyway.
+Overhead is the number of ciphertext bytes that aren't the input or
+padding: the prefix and suffix, plus an IV or nonce if the oracle sends
+one. It's -1 if the length doesn't depend on the input alone.
+PrefixLength and SuffixLength are only found for deterministic oracles,
+and are -1 otherwise; an IV or nonce sent in front counts as prefix.
+
+Confidence is the fraction of DetectMode's probes that agreed with Mode.
+An oracle that picks a new mode on every call gets a low confidence.
+*/
+type ModeReport struct {
+	Mode          CipherMode
+	Confidence    float64
+	BlockSize     int
+	Deterministic bool
+	Overhead      int
+	PrefixLength  int
+	SuffixLength  int
+	Queries       int
+}
+
+// ModeProbes is how many chosen inputs DetectMode sends to tell ECB from
+// CBC.
+const ModeProbes = 8
+
+type modeDetector struct {
+	oracle func([]byte) []byte
+	report ModeReport
+}
+
+func (d *modeDetector) query(input []byte) []byte {
+	d.report.Queries++
+	return d.oracle(input)
+}
+
+/*
+DetectMode works out how oracle encrypts its input, using inputs of its own
+choosing.
+
+The block size comes from how the ciphertext length grows as the input
+does. A length that grows a byte at a time means a stream mode (CTR, OFB,
+CFB and the like). Otherwise, input long enough to fill several aligned
+blocks with the same bytes encrypts to repeated blocks under ECB and not
+under CBC or any other chained mode, which are all reported as CBC.
+*/
+func DetectMode(oracle func([]byte) []byte) ModeReport {
+	d := &modeDetector{oracle: oracle}
+	d.report.Overhead = -1
+	d.report.PrefixLength = -1
+	d.report.SuffixLength = -1
+
+	lengths := make([]int, 2*maxECBBlockSize+1)
+	bs := 0
+	for n := range lengths {
+		lengths[n] = len(d.query(fill(ecbFiller, n)))
+		bs = gcd(bs, lengths[n])
+	}
+	d.report.BlockSize = bs
+	if bs == 0 || bs > maxECBBlockSize {
+		return d.report
+	}
+
+	probe := fill(ecbFiller, 4*bs)
+	d.report.Deterministic = bytes.Equal(d.query(probe), d.query(probe))
+	d.measureOverhead(lengths)
+	if bs == 1 {
+		d.classifyStream(lengths)
+	} else {
+		d.classifyBlock()
+	}
+	if d.report.Deterministic {
+		if d.report.Mode == ModeCBC {
+			d.checkPaddedStream()
+		}
+		d.measurePrefix()
+	}
+	return d.report
+}
+
+/*
+checkPaddedStream catches a stream mode that pads its input to whole blocks
+anyway, which looks like CBC from the lengths and the probes. Changing one
+byte of input changes one byte of a stream mode's output, but everything
+from that block on in CBC.
+*/
+func (d *modeDetector) checkPaddedStream() {
+	a, b := d.query([]byte{'X'}), d.query([]byte{'Y'})
+	if len(a) != len(b) {
+		return
+	}
+	changed := 0
+	for i := range a {
+		if a[i] != b[i] {
+			changed++
+		}
+	}
+	if changed == 1 {
+		d.report.Mode = ModeStream
+	}
+}
+
+// measureOverhead finds the first input length that makes the ciphertext
+// longer, if the lengths are consistent.
+func (d *modeDetector) measureOverhead(lengths []int) {
+	bs := d.report.BlockSize
+	for n := 0; n+bs < len(lengths); n++ {
+		if lengths[n+bs] != lengths[n]+bs || lengths[n+1] < lengths[n] {
+			return
+		}
+	}
+	if bs == 1 {
+		d.report.Overhead = lengths[0]
+		return
+	}
+	for n := 1; n <= bs; n++ {
+		if lengths[n] > lengths[0] {
+			//PKCS#7 style padding always adds at least one byte
+			d.report.Overhead = lengths[0] - n
+			return
+		}
+	}
+}
+
+// classifyStream counts how many input bytes added exactly one byte to
+// the ciphertext.
+func (d *modeDetector) classifyStream(lengths []int) {
+	agree := 0
+	for n := 1; n < len(lengths); n++ {
+		if lengths[n] == lengths[n-1]+1 {
+			agree++
+		}
+	}
+	d.report.Mode = ModeStream
+	d.report.Confidence = float64(agree) / float64(len(lengths)-1)
+}
+
+/*
+classifyBlock sends four blocks' worth of the same byte ModeProbes times.
+Whatever the prefix, at least three whole blocks of it are aligned, and ECB
+always encrypts them to repeated blocks. A chained mode repeats a block by
+chance with negligible probability.
+*/
+func (d *modeDetector) classifyBlock() {
+	bs := d.report.BlockSize
+	ecb := 0
+	for i := 0; i < ModeProbes; i++ {
+		if findAdjacent(d.query(fill(ecbFiller, 4*bs)), bs, nil) >= 0 {
+			ecb++
+		}
+	}
+	switch {
+	case 2*ecb > ModeProbes:
+		d.report.Mode = ModeECB
+		d.report.Confidence = float64(ecb) / ModeProbes
+	case 2*ecb < ModeProbes:
+		d.report.Mode = ModeCBC
+		d.report.Confidence = float64(ModeProbes-ecb) / ModeProbes
+	default:
+		d.report.Confidence = 0.5
+	}
+}
+
+// firstDifference returns the index of the first byte where a and b
+// differ, or -1 if one is a prefix of the other.
+func firstDifference(a, b []byte) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			return i
+		}
+	}
+	return -1
+}
+
+/*
+measurePrefix finds where the input starts in the ciphertext of a
+deterministic oracle, by changing one byte of input and seeing where the
+ciphertext first changes. In a stream mode that's the prefix length. In a
+block mode it's the block the input starts in; pushing the changed byte
+along with filler until the change moves into the next block gives the
+position within it.
+*/
+func (d *modeDetector) measurePrefix() {
+	bs := d.report.BlockSize
+	diff := func(k int) int {
+		filler := fill(ecbFiller, k)
+		return firstDifference(d.query(append(filler, 'X')), d.query(append(filler, 'Y')))
+	}
+	first := diff(0)
+	if first < 0 {
+		return
+	}
+	prefix := -1
+	if d.report.Mode == ModeStream {
+		prefix = first
+	} else {
+		block := first / bs
+		for k := 1; k <= bs; k++ {
+			if diff(k)/bs > block {
+				prefix = (block+1)*bs - k
+				break
+			}
+		}
+	}
+	if prefix < 0 {
+		return
+	}
+	d.report.PrefixLength = prefix
+	if d.report.Overhead >= prefix {
+		d.report.SuffixLength = d.report.Overhead - prefix
+	}
+}
+
+// String summarises the report on one line.
+func (r ModeReport) String() string {
+	return fmt.Sprintf("%v (confidence %.2f), block size %d, prefix %d, suffix %d, overhead %d, deterministic %v, %d queries",
+		r.Mode, r.Confidence, r.BlockSize, r.PrefixLength, r.SuffixLength, r.Overhead, r.Deterministic, r.Queries)
+}
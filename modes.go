@@ -0,0 +1,304 @@
+package cryptopals
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+	"fmt"
+)
+
+/*
+More block cipher modes, written by hand on top of cipher.Block like the
+ECB and CBC BlockModes: CFB (full-block and 8-bit), OFB, PCBC and XTS.
+
+CFB and OFB turn the block cipher into a stream cipher, so they implement
+cipher.Stream and need no padding. PCBC is a BlockMode like CBC. XTS is a
+tweakable mode for disk sectors and has its own API.
+*/
+
+type cfb struct {
+	b         cipher.Block
+	register  []byte
+	keystream []byte
+	used      int
+	decrypt   bool
+}
+
+func newCFB(b cipher.Block, iv []byte, decrypt bool) cipher.Stream {
+	return &cfb{
+		b:         b,
+		register:  copyIV(b, iv),
+		keystream: make([]byte, b.BlockSize()),
+		used:      b.BlockSize(),
+		decrypt:   decrypt,
+	}
+}
+
+// NewCFBEncrypter returns a Stream that encrypts in full-block CFB mode
+// (CFB128 for AES). It panics if iv isn't one block long.
+func NewCFBEncrypter(b cipher.Block, iv []byte) cipher.Stream {
+	return newCFB(b, iv, false)
+}
+
+// NewCFBDecrypter returns a Stream that decrypts in full-block CFB mode.
+// It panics if iv isn't one block long.
+func NewCFBDecrypter(b cipher.Block, iv []byte) cipher.Stream {
+	return newCFB(b, iv, true)
+}
+
+func (x *cfb) XORKeyStream(dst, src []byte) {
+	if len(dst) < len(src) {
+		panic("cryptopals: output smaller than input")
+	}
+	bs := len(x.register)
+	for i, in := range src {
+		if x.used == bs {
+			x.b.Encrypt(x.keystream, x.register)
+			x.used = 0
+		}
+		out := in ^ x.keystream[x.used]
+		//the register fills up with ciphertext, which is the input when
+		//decrypting and the output when encrypting
+		if x.decrypt {
+			x.register[x.used] = in
+		} else {
+			x.register[x.used] = out
+		}
+		dst[i] = out
+		x.used++
+	}
+}
+
+type cfb8 struct {
+	b        cipher.Block
+	register []byte
+	out      []byte
+	decrypt  bool
+}
+
+func newCFB8(b cipher.Block, iv []byte, decrypt bool) cipher.Stream {
+	return &cfb8{
+		b:        b,
+		register: copyIV(b, iv),
+		out:      make([]byte, b.BlockSize()),
+		decrypt:  decrypt,
+	}
+}
+
+// NewCFB8Encrypter returns a Stream that encrypts in 8-bit CFB mode, which
+// runs the block cipher once for every byte. It panics if iv isn't one
+// block long.
+func NewCFB8Encrypter(b cipher.Block, iv []byte) cipher.Stream {
+	return newCFB8(b, iv, false)
+}
+
+// NewCFB8Decrypter returns a Stream that decrypts in 8-bit CFB mode. It
+// panics if iv isn't one block long.
+func NewCFB8Decrypter(b cipher.Block, iv []byte) cipher.Stream {
+	return newCFB8(b, iv, true)
+}
+
+func (x *cfb8) XORKeyStream(dst, src []byte) {
+	if len(dst) < len(src) {
+		panic("cryptopals: output smaller than input")
+	}
+	for i, in := range src {
+		x.b.Encrypt(x.out, x.register)
+		out := in ^ x.out[0]
+		ct := out
+		if x.decrypt {
+			ct = in
+		}
+		copy(x.register, x.register[1:])
+		x.register[len(x.register)-1] = ct
+		dst[i] = out
+	}
+}
+
+type ofb struct {
+	b        cipher.Block
+	register []byte
+	used     int
+}
+
+// NewOFB returns a Stream that encrypts or decrypts in OFB mode. It panics
+// if iv isn't one block long.
+func NewOFB(b cipher.Block, iv []byte) cipher.Stream {
+	return &ofb{b, copyIV(b, iv), b.BlockSize()}
+}
+
+func (x *ofb) XORKeyStream(dst, src []byte) {
+	if len(dst) < len(src) {
+		panic("cryptopals: output smaller than input")
+	}
+	for i, in := range src {
+		if x.used == len(x.register) {
+			x.b.Encrypt(x.register, x.register)
+			x.used = 0
+		}
+		dst[i] = in ^ x.register[x.used]
+		x.used++
+	}
+}
+
+/*
+PCBC (propagating CBC) mixes both the previous plaintext and the previous
+ciphertext block into each block:
+
+C_i = E(P_i XOR P_i-1 XOR C_i-1), with P_0 XOR C_0 = IV
+
+so an error in one ciphertext block garbles every block after it.
+*/
+type pcbcEncrypter struct {
+	b    cipher.Block
+	prev []byte
+}
+
+type pcbcDecrypter struct {
+	b    cipher.Block
+	prev []byte
+}
+
+// NewPCBCEncrypter returns a BlockMode that encrypts in PCBC mode. It
+// panics if iv isn't one block long.
+func NewPCBCEncrypter(b cipher.Block, iv []byte) cipher.BlockMode {
+	return &pcbcEncrypter{b, copyIV(b, iv)}
+}
+
+// NewPCBCDecrypter returns a BlockMode that decrypts in PCBC mode. It
+// panics if iv isn't one block long.
+func NewPCBCDecrypter(b cipher.Block, iv []byte) cipher.BlockMode {
+	return &pcbcDecrypter{b, copyIV(b, iv)}
+}
+
+func (x *pcbcEncrypter) BlockSize() int { return x.b.BlockSize() }
+func (x *pcbcDecrypter) BlockSize() int { return x.b.BlockSize() }
+
+func (x *pcbcEncrypter) CryptBlocks(dst, src []byte) {
+	bs := x.b.BlockSize()
+	checkBlocks(bs, dst, src)
+	for i := 0; i < len(src); i += bs {
+		pt := make([]byte, bs)
+		copy(pt, src[i:i+bs])
+		x.b.Encrypt(dst[i:i+bs], XOr(pt, x.prev))
+		x.prev = XOr(pt, dst[i:i+bs])
+	}
+}
+
+func (x *pcbcDecrypter) CryptBlocks(dst, src []byte) {
+	bs := x.b.BlockSize()
+	checkBlocks(bs, dst, src)
+	for i := 0; i < len(src); i += bs {
+		ct := make([]byte, bs)
+		copy(ct, src[i:i+bs])
+		x.b.Decrypt(dst[i:i+bs], ct)
+		copy(dst[i:i+bs], XOr(dst[i:i+bs], x.prev))
+		x.prev = XOr(dst[i:i+bs], ct)
+	}
+}
+
+/*
+XTS is the XEX-based tweaked-codebook mode with ciphertext stealing from
+IEEE 1619 and NIST SP 800-38E, used for disk encryption. Each sector is
+encrypted on its own under a tweak derived from its sector number, so
+sectors can be read and written independently and no IV is stored. A
+sector doesn't have to be a whole number of blocks, but it does have to be
+at least one block long.
+*/
+type XTS struct {
+	k1, k2 cipher.Block
+}
+
+// NewXTS creates an XTS cipher from the data key k1 and the tweak key k2.
+// Both need a 16-byte block size.
+func NewXTS(k1, k2 cipher.Block) (*XTS, error) {
+	if k1.BlockSize() != 16 || k2.BlockSize() != 16 {
+		return nil, fmt.Errorf("%w: XTS needs a 16-byte block cipher", ErrUnsupportedBlockSize)
+	}
+	return &XTS{k1, k2}, nil
+}
+
+// mulAlpha multiplies the tweak by the primitive element of GF(2^128),
+// using the little-endian convention of IEEE 1619.
+func mulAlpha(t []byte) {
+	carry := t[15] >> 7
+	for i := 15; i > 0; i-- {
+		t[i] = t[i]<<1 | t[i-1]>>7
+	}
+	t[0] <<= 1
+	if carry == 1 {
+		t[0] ^= 0x87
+	}
+}
+
+func (x *XTS) tweak(sectorNum uint64) []byte {
+	t := make([]byte, 16)
+	binary.LittleEndian.PutUint64(t, sectorNum)
+	x.k2.Encrypt(t, t)
+	return t
+}
+
+func (x *XTS) cryptBlock(dst, src, tweak []byte, decrypt bool) {
+	pp := XOr(src, tweak)
+	if decrypt {
+		x.k1.Decrypt(pp, pp)
+	} else {
+		x.k1.Encrypt(pp, pp)
+	}
+	copy(dst, XOr(pp, tweak))
+}
+
+// Encrypt encrypts one sector. dst and src may overlap exactly.
+func (x *XTS) Encrypt(dst, src []byte, sectorNum uint64) error {
+	return x.crypt(dst, src, sectorNum, false)
+}
+
+// Decrypt decrypts one sector. dst and src may overlap exactly.
+func (x *XTS) Decrypt(dst, src []byte, sectorNum uint64) error {
+	return x.crypt(dst, src, sectorNum, true)
+}
+
+func (x *XTS) crypt(dst, src []byte, sectorNum uint64, decrypt bool) error {
+	if len(src) < 16 {
+		return fmt.Errorf("%w: an XTS sector needs at least 16 bytes, got %d", ErrInvalidLength, len(src))
+	}
+	if len(dst) < len(src) {
+		return fmt.Errorf("%w: output smaller than input", ErrLengthMismatch)
+	}
+	t := x.tweak(sectorNum)
+	full := len(src) / 16
+	r := len(src) % 16
+	if r != 0 {
+		//the last full block takes part in ciphertext stealing
+		full--
+	}
+	for i := 0; i < full; i++ {
+		x.cryptBlock(dst[i*16:(i+1)*16], src[i*16:(i+1)*16], t, decrypt)
+		mulAlpha(t)
+	}
+	if r == 0 {
+		return nil
+	}
+
+	/*
+		Ciphertext stealing: the last full block is processed, the start of
+		the result becomes the short final block, and the rest of it pads
+		the short block out to be processed into the last full position.
+		Decryption has to use the two tweaks the other way round.
+	*/
+	last := src[full*16 : full*16+16]
+	tail := make([]byte, r)
+	copy(tail, src[full*16+16:])
+	firstTweak, secondTweak := t, make([]byte, 16)
+	copy(secondTweak, t)
+	mulAlpha(secondTweak)
+	if decrypt {
+		firstTweak, secondTweak = secondTweak, firstTweak
+	}
+
+	cc := make([]byte, 16)
+	x.cryptBlock(cc, last, firstTweak, decrypt)
+	pp := append(tail, cc[r:]...)
+	copy(dst[full*16+16:], cc[:r])
+	x.cryptBlock(dst[full*16:full*16+16], pp, secondTweak, decrypt)
+	return nil
+}
@@ -0,0 +1,161 @@
+package cryptopals
+
+import "fmt"
+
+/*
+MT19937-64, the 64-bit Mersenne Twister, following the reference
+mt19937-64.c. It has 312 words of state rather than 624, different twist
+and tempering constants, and the same weakness: every output is a word of
+state run through an invertible tempering function, so 312 outputs give
+away the whole state.
+*/
+
+const (
+	mt64N         = 312
+	mt64M         = 156
+	mt64MatrixA   = 0xB5026F5AA96619E9
+	mt64UpperMask = 0xFFFFFFFF80000000
+	mt64LowerMask = 0x7FFFFFFF
+)
+
+// MersenneTwister64 is MT19937-64. Like MersenneTwister, the zero value is
+// seeded with MT19937DefaultSeed on first use.
+type MersenneTwister64 struct {
+	state []uint64
+	index int
+}
+
+// NewMT19937_64 returns a generator seeded with seed, like init_genrand64.
+func NewMT19937_64(seed uint64) *MersenneTwister64 {
+	mt := &MersenneTwister64{}
+	mt.Seed(seed)
+	return mt
+}
+
+// Seed initializes the generator from a seed, like init_genrand64.
+func (mt *MersenneTwister64) Seed(seed uint64) {
+	mt.state = make([]uint64, mt64N)
+	mt.state[0] = seed
+	for i := 1; i < mt64N; i++ {
+		mt.state[i] = 6364136223846793005*(mt.state[i-1]^(mt.state[i-1]>>62)) + uint64(i)
+	}
+	mt.index = 0
+}
+
+// SeedByArray initializes the generator from an array of any length, like
+// init_by_array64. An empty key is treated as {0}.
+func (mt *MersenneTwister64) SeedByArray(key []uint64) {
+	if len(key) == 0 {
+		key = []uint64{0}
+	}
+	mt.Seed(19650218)
+	i, j := 1, 0
+	k := len(key)
+	if k < mt64N {
+		k = mt64N
+	}
+	for ; k > 0; k-- {
+		mt.state[i] = (mt.state[i] ^ ((mt.state[i-1] ^ (mt.state[i-1] >> 62)) * 3935559000370003845)) + key[j%len(key)] + uint64(j%len(key))
+		i++
+		j++
+		if i >= mt64N {
+			mt.state[0] = mt.state[mt64N-1]
+			i = 1
+		}
+	}
+	for k = mt64N - 1; k > 0; k-- {
+		mt.state[i] = (mt.state[i] ^ ((mt.state[i-1] ^ (mt.state[i-1] >> 62)) * 2862933555777941757)) - uint64(i)
+		i++
+		if i >= mt64N {
+			mt.state[0] = mt.state[mt64N-1]
+			i = 1
+		}
+	}
+	mt.state[0] = 1 << 63 //MSB is 1, assuring a non-zero initial array
+}
+
+// generateNumbers twists the 312 words of state.
+func (mt *MersenneTwister64) generateNumbers() {
+	for i := 0; i < mt64N; i++ {
+		y := mt.state[i]&mt64UpperMask | mt.state[(i+1)%mt64N]&mt64LowerMask
+		mt.state[i] = mt.state[(i+mt64M)%mt64N] ^ (y >> 1)
+		if y&1 == 1 {
+			mt.state[i] ^= mt64MatrixA
+		}
+	}
+}
+
+// temperMT64 is MT19937-64's tempering function.
+func temperMT64(y uint64) uint64 {
+	y ^= (y >> 29) & 0x5555555555555555
+	y ^= (y << 17) & 0x71D67FFFEDA60000
+	y ^= (y << 37) & 0xFFF7EEE000000000
+	y ^= (y >> 43)
+	return y
+}
+
+// Uint64 returns the next tempered output, twisting the state every 312
+// numbers.
+func (mt *MersenneTwister64) Uint64() uint64 {
+	if mt.state == nil {
+		mt.Seed(MT19937DefaultSeed)
+	}
+	if mt.index == 0 {
+		mt.generateNumbers()
+	}
+	y := temperMT64(mt.state[mt.index])
+	mt.index = (mt.index + 1) % mt64N
+	return y
+}
+
+// Float64 returns a number in [0, 1) with 53 bits of precision, like
+// genrand64_res53.
+func (mt *MersenneTwister64) Float64() float64 {
+	return float64(mt.Uint64()>>11) / 9007199254740992
+}
+
+/*
+unshiftRight64 inverts y ^= (y >> shift) & mask. Each pass gets another
+shift bits right, working down from the top bits, which y kept as they were.
+unshiftLeft64 is the same from the bottom up.
+*/
+func unshiftRight64(y uint64, shift uint, mask uint64) uint64 {
+	x := y
+	for i := uint(0); i < 64; i += shift {
+		x = y ^ (x>>shift)&mask
+	}
+	return x
+}
+
+func unshiftLeft64(y uint64, shift uint, mask uint64) uint64 {
+	x := y
+	for i := uint(0); i < 64; i += shift {
+		x = y ^ (x<<shift)&mask
+	}
+	return x
+}
+
+// UntemperMT64 turns an MT19937-64 output back into the word of state it
+// came from.
+func UntemperMT64(y uint64) uint64 {
+	y = unshiftRight64(y, 43, 0xFFFFFFFFFFFFFFFF)
+	y = unshiftLeft64(y, 37, 0xFFF7EEE000000000)
+	y = unshiftLeft64(y, 17, 0x71D67FFFEDA60000)
+	return unshiftRight64(y, 29, 0x5555555555555555)
+}
+
+/*
+CloneMT19937_64 rebuilds a generator from 312 consecutive outputs that
+start at a twist, such as the first 312 after seeding, as challenge 23 does
+for the 32-bit generator. The clone's outputs are the ones that follow.
+*/
+func CloneMT19937_64(outputs []uint64) (*MersenneTwister64, error) {
+	if len(outputs) != mt64N {
+		return nil, fmt.Errorf("%w: %d outputs, expected %d", ErrInvalidLength, len(outputs), mt64N)
+	}
+	state := make([]uint64, mt64N)
+	for i, y := range outputs {
+		state[i] = UntemperMT64(y)
+	}
+	return &MersenneTwister64{state: state}, nil
+}
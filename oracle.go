@@ -0,0 +1,280 @@
+package cryptopals
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+/*
+Accounting for the oracles the attacks run against. MeteredOracle wraps an
+oracle of the form func([]byte) []byte and counts its queries, times them,
+enforces a budget, and can write every query and response to a transcript.
+A transcript can later stand in for the real oracle, so a failed run can be
+stepped through again without the key.
+
+Oracles with other shapes are adapted to and from func([]byte) []byte: a
+bool answer becomes a single 0 or 1 byte, and a PaddingOracle's IV and
+ciphertext are sent as one buffer.
+
+The attacks that return errors also take a CheckedOracle (or a
+CheckedPaddingOracle), which can fail: MeteredOracle.Query is one, so an
+oracle that is out of budget or a replay that has no answer just stops the
+attack with that error. The attacks that take plain functions have no way
+to return an error from one, so Func panics instead, and Run turns that
+panic back into an error. Func without Run panics all the way out.
+*/
+
+// CheckedOracle is an oracle that can fail, such as one that is out of
+// budget or talks to something over a network.
+type CheckedOracle func([]byte) ([]byte, error)
+
+// CheckedPaddingOracle is a PaddingOracle that can fail.
+type CheckedPaddingOracle func(iv, ct []byte) (bool, error)
+
+// UncheckedOracle adapts a plain oracle to a CheckedOracle that never fails.
+func UncheckedOracle(oracle func([]byte) []byte) CheckedOracle {
+	return func(input []byte) ([]byte, error) {
+		return oracle(input), nil
+	}
+}
+
+// OracleExchange is one query to an oracle and its response.
+type OracleExchange struct {
+	Query    []byte
+	Response []byte
+	Latency  time.Duration
+}
+
+// QueryBudgetError is returned when a MeteredOracle has used up its budget.
+// It wraps ErrQueryBudgetExceeded.
+type QueryBudgetError struct {
+	Budget int
+}
+
+func (e *QueryBudgetError) Error() string {
+	return fmt.Sprintf("oracle query budget of %d exceeded", e.Budget)
+}
+
+func (e *QueryBudgetError) Unwrap() error {
+	return ErrQueryBudgetExceeded
+}
+
+// oraclePanic carries an oracle's error out through an attack that has no
+// way to return it.
+type oraclePanic struct {
+	err error
+}
+
+// MeteredOracle counts, times and optionally records the queries made to
+// an oracle. MaxQueries limits how many are allowed; 0 means no limit.
+type MeteredOracle struct {
+	MaxQueries   int
+	Queries      int
+	TotalLatency time.Duration
+	MaxLatency   time.Duration
+
+	oracle CheckedOracle
+	record *json.Encoder
+}
+
+// NewMeteredOracle wraps oracle with no budget.
+func NewMeteredOracle(oracle func([]byte) []byte) *MeteredOracle {
+	return NewMeteredCheckedOracle(UncheckedOracle(oracle))
+}
+
+// NewMeteredCheckedOracle wraps an oracle that can fail, such as
+// ReplayOracleChecked, with no budget. Its errors are returned by Query.
+func NewMeteredCheckedOracle(oracle CheckedOracle) *MeteredOracle {
+	return &MeteredOracle{oracle: oracle}
+}
+
+// Record writes every exchange from now on to w, one JSON object per line.
+// The transcript can be read back with ReadTranscript.
+func (m *MeteredOracle) Record(w io.Writer) {
+	m.record = json.NewEncoder(w)
+}
+
+// Query sends input to the oracle. It returns a *QueryBudgetError if the
+// budget has been used up, and any error from the oracle or from writing
+// the transcript. A query that fails still counts.
+func (m *MeteredOracle) Query(input []byte) ([]byte, error) {
+	if m.MaxQueries > 0 && m.Queries >= m.MaxQueries {
+		return nil, &QueryBudgetError{m.MaxQueries}
+	}
+	m.Queries++
+	start := time.Now()
+	response, err := m.oracle(input)
+	latency := time.Since(start)
+	m.TotalLatency += latency
+	if latency > m.MaxLatency {
+		m.MaxLatency = latency
+	}
+	if err != nil {
+		return nil, err
+	}
+	if m.record != nil {
+		if err := m.record.Encode(OracleExchange{input, response, latency}); err != nil {
+			return nil, err
+		}
+	}
+	return response, nil
+}
+
+// MeanLatency returns the average time the oracle took to answer.
+func (m *MeteredOracle) MeanLatency() time.Duration {
+	if m.Queries == 0 {
+		return 0
+	}
+	return m.TotalLatency / time.Duration(m.Queries)
+}
+
+/*
+Func returns the metered oracle as a plain function, for the attacks that
+don't take a CheckedOracle. Any error from Query panics; call the attack
+inside Run, or the panic isn't recovered.
+*/
+func (m *MeteredOracle) Func() func([]byte) []byte {
+	return func(input []byte) []byte {
+		response, err := m.Query(input)
+		if err != nil {
+			panic(oraclePanic{err})
+		}
+		return response
+	}
+}
+
+// BoolFunc is Func for an oracle that was adapted with BytesOracle.
+func (m *MeteredOracle) BoolFunc() func([]byte) bool {
+	f := m.Func()
+	return func(input []byte) bool {
+		response := f(input)
+		return len(response) == 1 && response[0] == 1
+	}
+}
+
+// CheckedPaddingOracle is Query for an oracle that was adapted with
+// PaddingOracleBytes.
+func (m *MeteredOracle) CheckedPaddingOracle() CheckedPaddingOracle {
+	return func(iv, ct []byte) (bool, error) {
+		response, err := m.Query(append(append([]byte{}, iv...), ct...))
+		if err != nil {
+			return false, err
+		}
+		return len(response) == 1 && response[0] == 1, nil
+	}
+}
+
+// PaddingOracle is Func for an oracle that was adapted with
+// PaddingOracleBytes.
+func (m *MeteredOracle) PaddingOracle() PaddingOracle {
+	f := m.BoolFunc()
+	return func(iv, ct []byte) bool {
+		return f(append(append([]byte{}, iv...), ct...))
+	}
+}
+
+/*
+Run calls attack, and returns the error from an oracle that ran out of
+budget or a replay that ran out of answers while it was running. Other
+panics are passed on.
+*/
+func Run(attack func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			p, ok := r.(oraclePanic)
+			if !ok {
+				panic(r)
+			}
+			err = p.err
+		}
+	}()
+	return attack()
+}
+
+// BytesOracle adapts an oracle that answers with a bool, such as a
+// padding check, so it can be metered.
+func BytesOracle(oracle func([]byte) bool) func([]byte) []byte {
+	return func(input []byte) []byte {
+		if oracle(input) {
+			return []byte{1}
+		}
+		return []byte{0}
+	}
+}
+
+// PaddingOracleBytes adapts a PaddingOracle so it can be metered. The query
+// is the IV followed by the ciphertext.
+func PaddingOracleBytes(oracle PaddingOracle, blockSize int) func([]byte) []byte {
+	return BytesOracle(func(input []byte) bool {
+		if len(input) < blockSize {
+			return false
+		}
+		return oracle(input[:blockSize], input[blockSize:])
+	})
+}
+
+// ReadTranscript reads the exchanges written by Record.
+func ReadTranscript(r io.Reader) ([]OracleExchange, error) {
+	var exchanges []OracleExchange
+	decoder := json.NewDecoder(bufio.NewReader(r))
+	for {
+		var e OracleExchange
+		err := decoder.Decode(&e)
+		if err == io.EOF {
+			return exchanges, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		exchanges = append(exchanges, e)
+	}
+}
+
+// LoadTranscript reads a transcript file written by Record.
+func LoadTranscript(filename string) ([]OracleExchange, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadTranscript(f)
+}
+
+/*
+ReplayOracleChecked returns an oracle that answers from a transcript
+instead of the real thing. A query gets the response that was recorded for
+the same input; if an input was sent more than once, the responses come
+back in the order they were recorded. A query that isn't in the transcript
+returns an error wrapping ErrNotInTranscript.
+*/
+func ReplayOracleChecked(exchanges []OracleExchange) CheckedOracle {
+	responses := make(map[string][][]byte)
+	for _, e := range exchanges {
+		responses[string(e.Query)] = append(responses[string(e.Query)], e.Response)
+	}
+	return func(input []byte) ([]byte, error) {
+		queue := responses[string(input)]
+		if len(queue) == 0 {
+			return nil, fmt.Errorf("%w: %d byte query", ErrNotInTranscript, len(input))
+		}
+		responses[string(input)] = queue[1:]
+		return queue[0], nil
+	}
+}
+
+// ReplayOracle is ReplayOracleChecked as a plain function. A query that
+// isn't in the transcript panics with the error, which Run returns.
+func ReplayOracle(exchanges []OracleExchange) func([]byte) []byte {
+	replay := ReplayOracleChecked(exchanges)
+	return func(input []byte) []byte {
+		response, err := replay(input)
+		if err != nil {
+			panic(oraclePanic{err})
+		}
+		return response
+	}
+}
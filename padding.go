@@ -0,0 +1,293 @@
+package cryptopals
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+)
+
+/*
+Padding schemes for block cipher modes. PKCS#7 (challenge 9) is the one
+used everywhere else in this package; the others are here so the padding
+oracle attacks can be pointed at them too.
+
+Pad never modifies its input. Unpad checks that the input is a whole number
+of blocks and that the padding is well formed, and returns a *PaddingError
+if it isn't. Note that the error says what was wrong with the padding,
+which is exactly what a padding oracle leaks.
+*/
+type Padding interface {
+	Pad(data []byte, blockSize int) ([]byte, error)
+	Unpad(data []byte, blockSize int) ([]byte, error)
+}
+
+// PaddingError is returned by Unpad for padding that isn't valid. It wraps
+// ErrInvalidPadding.
+type PaddingError struct {
+	Scheme string
+	Reason string
+}
+
+func (e *PaddingError) Error() string {
+	return fmt.Sprintf("invalid %v padding: %v", e.Scheme, e.Reason)
+}
+
+func (e *PaddingError) Unwrap() error {
+	return ErrInvalidPadding
+}
+
+// checkPadBlockSize checks the block size for schemes that store the
+// padding length in a single byte.
+func checkPadBlockSize(blockSize int) error {
+	if blockSize < 1 || blockSize > 255 {
+		return fmt.Errorf("%w: %d", ErrInvalidBlockSize, blockSize)
+	}
+	return nil
+}
+
+// checkUnpadInput checks that data is a non-empty, whole number of blocks.
+func checkUnpadInput(scheme string, data []byte, blockSize int) error {
+	if err := checkPadBlockSize(blockSize); err != nil {
+		return err
+	}
+	if len(data) == 0 {
+		return &PaddingError{scheme, "input is empty"}
+	}
+	if len(data)%blockSize != 0 {
+		return fmt.Errorf("%w: input is %d bytes", ErrNotBlockAligned, len(data))
+	}
+	return nil
+}
+
+// padLength returns how many bytes of padding take data to the next block
+// boundary. A whole block is added if data is already aligned.
+func padLength(data []byte, blockSize int) int {
+	return blockSize - len(data)%blockSize
+}
+
+// appendPadding copies data into a new slice with room for the padding.
+func appendPadding(data []byte, padding []byte) []byte {
+	out := make([]byte, len(data), len(data)+len(padding))
+	copy(out, data)
+	return append(out, padding...)
+}
+
+// trailingLength reads the padding length from the last byte, and checks
+// that it's between 1 and blockSize.
+func trailingLength(scheme string, data []byte, blockSize int) (int, error) {
+	n := int(data[len(data)-1])
+	if n == 0 || n > blockSize {
+		return 0, &PaddingError{scheme, fmt.Sprintf("length byte %d out of range", n)}
+	}
+	return n, nil
+}
+
+// PKCS7Padding fills with n bytes of value n.
+type PKCS7Padding struct{}
+
+func (PKCS7Padding) Pad(data []byte, blockSize int) ([]byte, error) {
+	if err := checkPadBlockSize(blockSize); err != nil {
+		return nil, err
+	}
+	n := padLength(data, blockSize)
+	padding := make([]byte, n)
+	for i := range padding {
+		padding[i] = byte(n)
+	}
+	return appendPadding(data, padding), nil
+}
+
+func (PKCS7Padding) Unpad(data []byte, blockSize int) ([]byte, error) {
+	if err := checkUnpadInput("PKCS#7", data, blockSize); err != nil {
+		return nil, err
+	}
+	n, err := trailingLength("PKCS#7", data, blockSize)
+	if err != nil {
+		return nil, err
+	}
+	return stripPKCS7(data, n)
+}
+
+// stripPKCS7 checks that the last n bytes all equal n and strips them.
+func stripPKCS7(data []byte, n int) ([]byte, error) {
+	for _, b := range data[len(data)-n:] {
+		if int(b) != n {
+			return nil, &PaddingError{"PKCS#7", "padding bytes don't match the length byte"}
+		}
+	}
+	return data[:len(data)-n], nil
+}
+
+// ConstantTimePKCS7Padding is PKCS#7 padding, unpadded with
+// StripPaddingConstantTime.
+type ConstantTimePKCS7Padding struct{}
+
+func (ConstantTimePKCS7Padding) Pad(data []byte, blockSize int) ([]byte, error) {
+	return PKCS7Padding{}.Pad(data, blockSize)
+}
+
+func (ConstantTimePKCS7Padding) Unpad(data []byte, blockSize int) ([]byte, error) {
+	return StripPaddingConstantTime(data, blockSize)
+}
+
+// ANSIX923Padding fills with zeros, then a final byte holding the length.
+type ANSIX923Padding struct{}
+
+func (ANSIX923Padding) Pad(data []byte, blockSize int) ([]byte, error) {
+	if err := checkPadBlockSize(blockSize); err != nil {
+		return nil, err
+	}
+	n := padLength(data, blockSize)
+	padding := make([]byte, n)
+	padding[n-1] = byte(n)
+	return appendPadding(data, padding), nil
+}
+
+func (ANSIX923Padding) Unpad(data []byte, blockSize int) ([]byte, error) {
+	if err := checkUnpadInput("ANSI X9.23", data, blockSize); err != nil {
+		return nil, err
+	}
+	n, err := trailingLength("ANSI X9.23", data, blockSize)
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range data[len(data)-n : len(data)-1] {
+		if b != 0 {
+			return nil, &PaddingError{"ANSI X9.23", "padding bytes aren't zero"}
+		}
+	}
+	return data[:len(data)-n], nil
+}
+
+// ISO10126Padding fills with random bytes, then a final byte holding the
+// length. Only the length byte can be checked when unpadding.
+type ISO10126Padding struct{}
+
+func (ISO10126Padding) Pad(data []byte, blockSize int) ([]byte, error) {
+	if err := checkPadBlockSize(blockSize); err != nil {
+		return nil, err
+	}
+	n := padLength(data, blockSize)
+	padding := make([]byte, n)
+	if _, err := rand.Read(padding[:n-1]); err != nil {
+		return nil, err
+	}
+	padding[n-1] = byte(n)
+	return appendPadding(data, padding), nil
+}
+
+func (ISO10126Padding) Unpad(data []byte, blockSize int) ([]byte, error) {
+	if err := checkUnpadInput("ISO 10126", data, blockSize); err != nil {
+		return nil, err
+	}
+	n, err := trailingLength("ISO 10126", data, blockSize)
+	if err != nil {
+		return nil, err
+	}
+	return data[:len(data)-n], nil
+}
+
+// ISO7816Padding appends a single 0x80 byte, then fills with zeros.
+type ISO7816Padding struct{}
+
+func (ISO7816Padding) Pad(data []byte, blockSize int) ([]byte, error) {
+	if blockSize < 1 {
+		return nil, fmt.Errorf("%w: %d", ErrInvalidBlockSize, blockSize)
+	}
+	padding := make([]byte, padLength(data, blockSize))
+	padding[0] = 0x80
+	return appendPadding(data, padding), nil
+}
+
+func (ISO7816Padding) Unpad(data []byte, blockSize int) ([]byte, error) {
+	if blockSize < 1 {
+		return nil, fmt.Errorf("%w: %d", ErrInvalidBlockSize, blockSize)
+	}
+	if len(data) == 0 {
+		return nil, &PaddingError{"ISO/IEC 7816-4", "input is empty"}
+	}
+	if len(data)%blockSize != 0 {
+		return nil, fmt.Errorf("%w: input is %d bytes", ErrNotBlockAligned, len(data))
+	}
+	for i := len(data) - 1; i >= len(data)-blockSize; i-- {
+		switch data[i] {
+		case 0:
+			continue
+		case 0x80:
+			return data[:i], nil
+		}
+		return nil, &PaddingError{"ISO/IEC 7816-4", "expected 0x80 before the zeros"}
+	}
+	return nil, &PaddingError{"ISO/IEC 7816-4", "no 0x80 in the last block"}
+}
+
+/*
+ZeroPadding fills with zeros up to the next block boundary, and adds
+nothing to data that is already aligned. It can't tell padding from
+trailing zeros in the data, so Unpad strips every zero in the last block;
+it only fails on input that isn't block aligned.
+*/
+type ZeroPadding struct{}
+
+func (ZeroPadding) Pad(data []byte, blockSize int) ([]byte, error) {
+	if blockSize < 1 {
+		return nil, fmt.Errorf("%w: %d", ErrInvalidBlockSize, blockSize)
+	}
+	n := padLength(data, blockSize) % blockSize
+	return appendPadding(data, make([]byte, n)), nil
+}
+
+func (ZeroPadding) Unpad(data []byte, blockSize int) ([]byte, error) {
+	if blockSize < 1 {
+		return nil, fmt.Errorf("%w: %d", ErrInvalidBlockSize, blockSize)
+	}
+	if len(data)%blockSize != 0 {
+		return nil, fmt.Errorf("%w: input is %d bytes", ErrNotBlockAligned, len(data))
+	}
+	end := len(data)
+	for end > 0 && end > len(data)-blockSize && data[end-1] == 0 {
+		end--
+	}
+	return data[:end], nil
+}
+
+// NoPadding adds nothing, and requires data to already be block aligned.
+type NoPadding struct{}
+
+func (NoPadding) Pad(data []byte, blockSize int) ([]byte, error) {
+	if blockSize < 1 {
+		return nil, fmt.Errorf("%w: %d", ErrInvalidBlockSize, blockSize)
+	}
+	if len(data)%blockSize != 0 {
+		return nil, fmt.Errorf("%w: input is %d bytes", ErrNotBlockAligned, len(data))
+	}
+	return appendPadding(data, nil), nil
+}
+
+func (NoPadding) Unpad(data []byte, blockSize int) ([]byte, error) {
+	return NoPadding{}.Pad(data, blockSize)
+}
+
+/*
+EncryptWithPadding pads pt with padding and encrypts it with mode, which
+can be any of the BlockModes (ECB, CBC, PCBC) over any block cipher.
+*/
+func EncryptWithPadding(mode cipher.BlockMode, padding Padding, pt []byte) ([]byte, error) {
+	padded, err := padding.Pad(pt, mode.BlockSize())
+	if err != nil {
+		return nil, err
+	}
+	mode.CryptBlocks(padded, padded)
+	return padded, nil
+}
+
+// DecryptWithPadding decrypts ct with mode and removes the padding.
+func DecryptWithPadding(mode cipher.BlockMode, padding Padding, ct []byte) ([]byte, error) {
+	bs := mode.BlockSize()
+	if len(ct)%bs != 0 {
+		return nil, fmt.Errorf("%w: ciphertext is %d bytes", ErrNotBlockAligned, len(ct))
+	}
+	pt := make([]byte, len(ct))
+	mode.CryptBlocks(pt, ct)
+	return padding.Unpad(pt, bs)
+}
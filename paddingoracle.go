@@ -0,0 +1,166 @@
+package cryptopals
+
+import "fmt"
+
+/*
+PaddingOracle reports whether ct, decrypted in CBC mode with iv, has valid
+padding. To attack a real service, write one of these that sends iv and ct
+the way the service expects them and turns its response into a bool.
+
+The attack only ever calls the oracle with a single block of ciphertext,
+and with an IV that it has made up. If the service has its own fixed IV,
+sending iv || ct as the ciphertext works just as well: the first block
+decrypts to garbage, but only the padding of the last block is checked.
+*/
+type PaddingOracle func(iv, ct []byte) bool
+
+// checked adapts a PaddingOracle to a CheckedPaddingOracle that never fails.
+func (oracle PaddingOracle) checked() CheckedPaddingOracle {
+	return func(iv, ct []byte) (bool, error) {
+		return oracle(iv, ct), nil
+	}
+}
+
+/*
+BreakPaddingOracle decrypts ct, which was encrypted in CBC mode with iv and
+PKCS#7 padding, using nothing but a padding oracle. It returns the
+plaintext with the padding stripped, and the number of oracle queries it
+took.
+
+Each block is attacked on its own by sending it after a forged previous
+block, and finding the forged bytes that make the padding valid one byte
+at a time from the end. Knowing the forged byte that gives padding value p
+gives that byte of the block's raw decryption, and XORing that with the
+real previous block gives the plaintext.
+*/
+func BreakPaddingOracle(oracle PaddingOracle, blockSize int, iv, ct []byte) ([]byte, int, error) {
+	return BreakPaddingOracleChecked(oracle.checked(), blockSize, iv, ct)
+}
+
+// BreakPaddingOracleChecked is BreakPaddingOracle for an oracle that can
+// fail. The first error from the oracle stops the attack and is returned
+// as it is.
+func BreakPaddingOracleChecked(oracle CheckedPaddingOracle, blockSize int, iv, ct []byte) ([]byte, int, error) {
+	if blockSize < 1 || blockSize > 255 {
+		return nil, 0, fmt.Errorf("%w: %d", ErrInvalidBlockSize, blockSize)
+	}
+	if len(iv) != blockSize {
+		return nil, 0, fmt.Errorf("%w: %d bytes", ErrInvalidIVSize, len(iv))
+	}
+	if len(ct) == 0 || len(ct)%blockSize != 0 {
+		return nil, 0, fmt.Errorf("%w: ciphertext is %d bytes", ErrNotBlockAligned, len(ct))
+	}
+
+	queries := 0
+	query := func(forged, block []byte) (bool, error) {
+		queries++
+		return oracle(forged, block)
+	}
+
+	var pt []byte
+	prev := iv
+	for _, block := range BreakIntoBlocks(ct, blockSize) {
+		intermediate, err := paddingOracleBlock(query, block)
+		if err != nil {
+			return nil, queries, err
+		}
+		pt = append(pt, XOr(intermediate, prev)...)
+		prev = block
+	}
+
+	stripped, err := PKCS7Padding{}.Unpad(pt, blockSize)
+	if err != nil {
+		return nil, queries, err
+	}
+	return stripped, queries, nil
+}
+
+// paddingOracleBlock recovers the raw block cipher decryption of block.
+func paddingOracleBlock(query CheckedPaddingOracle, block []byte) ([]byte, error) {
+	bs := len(block)
+	intermediate := make([]byte, bs)
+	forged := make([]byte, bs)
+	for pos := bs - 1; pos >= 0; pos-- {
+		pad := byte(bs - pos)
+		//make the bytes already found decrypt to the new padding value
+		for i := pos + 1; i < bs; i++ {
+			forged[i] = intermediate[i] ^ pad
+		}
+
+		found := false
+		for guess := 0; guess < 256 && !found; guess++ {
+			forged[pos] = byte(guess)
+			valid, err := query(forged, block)
+			if err != nil {
+				return nil, err
+			}
+			if !valid {
+				continue
+			}
+			/*
+				With the later bytes pinned to pad, the only way to get valid
+				padding is for this byte to decrypt to pad too - except for the
+				last byte, which is valid if it happens to hit \x02 after a real
+				\x02, and so on. Changing the byte before it rules that out.
+			*/
+			if pos == bs-1 && pos > 0 {
+				forged[pos-1] ^= 0xff
+				confirmed, err := query(forged, block)
+				forged[pos-1] ^= 0xff
+				if err != nil {
+					return nil, err
+				}
+				if !confirmed {
+					continue
+				}
+			}
+			intermediate[pos] = byte(guess) ^ pad
+			found = true
+		}
+		if !found {
+			return nil, fmt.Errorf("%w: no byte gives valid padding at offset %d", ErrPaddingOracleFailed, pos)
+		}
+	}
+	return intermediate, nil
+}
+
+/*
+ForgeWithPaddingOracle is CBC-R: it runs the padding oracle attack
+backwards to encrypt pt without the key. It returns an IV and ciphertext
+that decrypt to pt with valid PKCS#7 padding, and the number of queries it
+took.
+
+Starting from a random final block, the oracle gives that block's raw
+decryption, and the block before it is chosen so that the two XOR to the
+last block of plaintext. That block is then attacked in turn, and so on
+back to the IV. The first block of the forgery is whatever the IV makes it,
+so unlike a real encryption nothing decrypts to garbage.
+*/
+func ForgeWithPaddingOracle(oracle PaddingOracle, blockSize int, pt []byte) (iv, ct []byte, queries int, err error) {
+	return ForgeWithPaddingOracleChecked(oracle.checked(), blockSize, pt)
+}
+
+// ForgeWithPaddingOracleChecked is ForgeWithPaddingOracle for an oracle
+// that can fail.
+func ForgeWithPaddingOracleChecked(oracle CheckedPaddingOracle, blockSize int, pt []byte) (iv, ct []byte, queries int, err error) {
+	padded, err := PKCS7Padding{}.Pad(pt, blockSize)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	query := func(forged, block []byte) (bool, error) {
+		queries++
+		return oracle(forged, block)
+	}
+
+	ptBlocks := BreakIntoBlocks(padded, blockSize)
+	blocks := make([][]byte, len(ptBlocks)+1)
+	blocks[len(ptBlocks)] = Key(blockSize)
+	for i := len(ptBlocks); i > 0; i-- {
+		intermediate, err := paddingOracleBlock(query, blocks[i])
+		if err != nil {
+			return nil, nil, queries, err
+		}
+		blocks[i-1] = XOr(intermediate, ptBlocks[i-1])
+	}
+	return blocks[0], Flatten(blocks[1:]), queries, nil
+}
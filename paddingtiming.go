@@ -0,0 +1,142 @@
+package cryptopals
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+/*
+A harness for showing that an unpad function's running time depends on the
+padding it's given. For every padding length from 1 to the block size it
+times batches of calls on validly padded input, shuffling the order of the
+lengths each round so that drift in the machine's speed is spread across
+all of them. It then compares the shortest and longest padding with Welch's
+t-test, and calls the difference significant if |t| is over 4.5, which is
+the threshold dudect uses.
+
+Timing on a shared machine is noisy, so treat the result as evidence rather
+than proof, and use enough samples that one bad scheduling decision doesn't
+decide it.
+*/
+
+// TimingSignificanceThreshold is the |t| above which a timing difference
+// is reported as significant.
+const TimingSignificanceThreshold = 4.5
+
+// PaddingLengthTiming summarises the batches timed for one padding length.
+// Mean and StdDev are per call, in nanoseconds.
+type PaddingLengthTiming struct {
+	PaddingLength int
+	Samples       int
+	Mean          float64
+	StdDev        float64
+}
+
+// PaddingTimingReport is the result of MeasureUnpadTiming.
+type PaddingTimingReport struct {
+	Lengths     []PaddingLengthTiming
+	T           float64
+	Significant bool
+}
+
+// timingSink keeps the compiler from discarding the calls being timed.
+var timingSink int
+
+/*
+MeasureUnpadTiming times unpad on inputs of two blocks with every valid
+padding length. samples is how many batches to time per length and
+iterations how many calls go into each batch, so that a batch takes long
+enough for the clock to measure.
+*/
+func MeasureUnpadTiming(unpad func([]byte) ([]byte, error), blockSize, samples, iterations int) (PaddingTimingReport, error) {
+	if err := checkPadBlockSize(blockSize); err != nil {
+		return PaddingTimingReport{}, err
+	}
+	if samples < 2 || iterations < 1 {
+		return PaddingTimingReport{}, fmt.Errorf("%w: need at least 2 samples and 1 iteration", ErrInvalidLength)
+	}
+
+	inputs := make([][]byte, blockSize)
+	for n := 1; n <= blockSize; n++ {
+		inputs[n-1] = Pad(make([]byte, 2*blockSize-n), blockSize)
+	}
+
+	times := make([][]float64, blockSize)
+	order := make([]int, blockSize)
+	for i := range order {
+		order[i] = i
+	}
+	for s := 0; s < samples; s++ {
+		for i := len(order) - 1; i > 0; i-- {
+			j := GetRandomInt(i + 1)
+			order[i], order[j] = order[j], order[i]
+		}
+		for _, i := range order {
+			in := inputs[i]
+			start := time.Now()
+			for k := 0; k < iterations; k++ {
+				out, _ := unpad(in)
+				timingSink += len(out)
+			}
+			elapsed := time.Since(start)
+			times[i] = append(times[i], float64(elapsed.Nanoseconds())/float64(iterations))
+		}
+	}
+
+	var report PaddingTimingReport
+	for i, ts := range times {
+		mean, variance := meanAndVariance(ts)
+		report.Lengths = append(report.Lengths, PaddingLengthTiming{
+			PaddingLength: i + 1,
+			Samples:       len(ts),
+			Mean:          mean,
+			StdDev:        math.Sqrt(variance),
+		})
+	}
+	report.T = welchT(times[0], times[blockSize-1])
+	report.Significant = math.Abs(report.T) > TimingSignificanceThreshold
+	return report, nil
+}
+
+// meanAndVariance returns the mean and unbiased sample variance of xs.
+func meanAndVariance(xs []float64) (float64, float64) {
+	var sum float64
+	for _, x := range xs {
+		sum += x
+	}
+	mean := sum / float64(len(xs))
+	var sq float64
+	for _, x := range xs {
+		sq += (x - mean) * (x - mean)
+	}
+	return mean, sq / float64(len(xs)-1)
+}
+
+// welchT returns Welch's t statistic for the difference in the means of
+// a and b.
+func welchT(a, b []float64) float64 {
+	ma, va := meanAndVariance(a)
+	mb, vb := meanAndVariance(b)
+	se := math.Sqrt(va/float64(len(a)) + vb/float64(len(b)))
+	if se == 0 {
+		return 0
+	}
+	return (ma - mb) / se
+}
+
+// String formats the report as a table, one row per padding length.
+func (r PaddingTimingReport) String() string {
+	var sb strings.Builder
+	sb.WriteString("padding  mean ns/call  stddev\n")
+	for _, l := range r.Lengths {
+		fmt.Fprintf(&sb, "%7d  %12.2f  %6.2f\n", l.PaddingLength, l.Mean, l.StdDev)
+	}
+	verdict := "not significant"
+	if r.Significant {
+		verdict = "significant"
+	}
+	fmt.Fprintf(&sb, "t = %.2f (%v at |t| > %v)\n", r.T, verdict, TimingSignificanceThreshold)
+	return sb.String()
+}
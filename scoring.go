@@ -0,0 +1,309 @@
+package cryptopals
+
+import (
+	"math"
+	"os"
+)
+
+/*
+Scorer rates how much a candidate plaintext looks like the kind of text
+we expect to find. Lower scores are better, so a Scorer can be dropped in
+anywhere the XOR breakers used to compare letter-frequency differences.
+*/
+type Scorer interface {
+	Score(pt []byte) float64
+}
+
+// ScorerFunc lets an ordinary function be used as a Scorer.
+type ScorerFunc func([]byte) float64
+
+func (f ScorerFunc) Score(pt []byte) float64 {
+	return f(pt)
+}
+
+var englishLetterFrequency = map[byte]float64{
+	' ': 0.182884,
+	'E': 0.102666,
+	'T': 0.075169,
+	'A': 0.065321,
+	'O': 0.061595,
+	'N': 0.057120,
+	'I': 0.056684,
+	'S': 0.053170,
+	'R': 0.049879,
+	'H': 0.049785,
+	'L': 0.033175,
+	'D': 0.032829,
+	'U': 0.022757,
+	'C': 0.022336,
+	'M': 0.020265,
+	'F': 0.019830,
+	'W': 0.017038,
+	'G': 0.016249,
+	'P': 0.015043,
+	'Y': 0.014276,
+	'B': 0.012588,
+	'V': 0.007961,
+	'K': 0.005609,
+	'X': 0.001409,
+	'J': 0.000975,
+	'Q': 0.000836,
+	'Z': 0.000512,
+}
+
+// englishSample is a short stretch of ordinary English prose that the
+// built-in bigram and trigram models are trained on.
+const englishSample = "It was the best of times for the small town by the river. " +
+	"Every morning the people who lived there would open their windows and look out " +
+	"over the water, and they would talk about the weather and the price of bread and " +
+	"the news that had come in on the last train. The children went to school at eight " +
+	"and came home for lunch, and in the afternoon they played in the fields behind the " +
+	"church until their mothers called them in for dinner. Nobody in the town had much " +
+	"money, but nobody was really poor either, and most of them thought that this was " +
+	"the way things should be. There was a library on the main street that was open " +
+	"three days a week, and an old man with a long white beard who sat at the desk and " +
+	"read the newspaper from the first page to the last. When somebody came in to borrow " +
+	"a book he would put the paper down and ask them what they were looking for, and then " +
+	"he would tell them that they should really read something else instead. He was " +
+	"usually right about that. In the summer there was a fair with music and dancing, " +
+	"and people came from all of the other towns along the river to see it. The young men " +
+	"would try to win prizes for the girls they liked, and the old women would sit in the " +
+	"shade and remember when they had been young themselves. At night there were lights " +
+	"strung between the trees, and the sound of the band could be heard from one end of " +
+	"the valley to the other. Then the autumn would come, and the leaves would turn, and " +
+	"everyone would say that the year had gone by much faster than the one before it. "
+
+func toUpperASCII(b byte) byte {
+	if b >= 'a' && b <= 'z' {
+		return b - ('a' - 'A')
+	}
+	return b
+}
+
+func foldASCII(b []byte) []byte {
+	out := make([]byte, len(b))
+	for i, c := range b {
+		out[i] = toUpperASCII(c)
+	}
+	return out
+}
+
+func isPrintableASCII(b byte) bool {
+	return (b >= 0x20 && b < 0x7f) || b == '\n' || b == '\r' || b == '\t'
+}
+
+/*
+LetterFrequencyScorer sums the absolute difference between the English
+letter distribution and the letter distribution of the plaintext, plus the
+fraction of bytes that aren't letters or spaces. It's the metric
+BreakSingleByteXOrCipher used to have built in, except that each letter's
+frequency is now counted properly; it used to be 1/len(pt) for any letter
+that turned up at all.
+*/
+type LetterFrequencyScorer struct{}
+
+func (LetterFrequencyScorer) Score(pt []byte) float64 {
+	if len(pt) == 0 {
+		return 0
+	}
+	observed := make(map[byte]float64)
+	otherCharacters := 0
+
+	for _, letter := range pt {
+		letter = toUpperASCII(letter)
+		if englishLetterFrequency[letter] > 0 {
+			observed[letter] += 1 / float64(len(pt))
+		} else {
+			otherCharacters += 1
+		}
+	}
+	var difference float64
+	for k, freq := range englishLetterFrequency {
+		difference += math.Abs(freq - observed[k])
+	}
+	difference += float64(otherCharacters) / float64(len(pt))
+	return difference
+}
+
+/*
+ChiSquaredScorer runs a chi-squared test of the plaintext's unigram counts
+against English. Printable bytes that aren't letters or spaces share one
+bucket, and unprintable bytes are expected to be very rare, so a single
+one of them costs a lot.
+*/
+type ChiSquaredScorer struct{}
+
+func (ChiSquaredScorer) Score(pt []byte) float64 {
+	if len(pt) == 0 {
+		return 0
+	}
+	const (
+		otherFrequency       = 0.02
+		unprintableFrequency = 0.0001
+	)
+	counts := make(map[byte]int)
+	other, unprintable := 0, 0
+	for _, b := range pt {
+		b = toUpperASCII(b)
+		if englishLetterFrequency[b] > 0 {
+			counts[b]++
+		} else if isPrintableASCII(b) {
+			other++
+		} else {
+			unprintable++
+		}
+	}
+
+	n := float64(len(pt))
+	chiSquared := func(observed int, frequency float64) float64 {
+		expected := frequency * n
+		d := float64(observed) - expected
+		return d * d / expected
+	}
+	var score float64
+	for k, freq := range englishLetterFrequency {
+		score += chiSquared(counts[k], freq)
+	}
+	score += chiSquared(other, otherFrequency)
+	score += chiSquared(unprintable, unprintableFrequency)
+	return score
+}
+
+/*
+PrintableScorer is a cheap heuristic that doesn't assume any particular
+language. Letters, digits and spaces are free, other printable ASCII costs
+a little and everything else costs a lot. The score is the average cost
+per byte.
+*/
+type PrintableScorer struct{}
+
+func (PrintableScorer) Score(pt []byte) float64 {
+	if len(pt) == 0 {
+		return 0
+	}
+	var cost float64
+	for _, b := range pt {
+		u := toUpperASCII(b)
+		switch {
+		case u == ' ' || (u >= 'A' && u <= 'Z') || (u >= '0' && u <= '9'):
+		case isPrintableASCII(b):
+			cost += 0.25
+		default:
+			cost += 1
+		}
+	}
+	return cost / float64(len(pt))
+}
+
+/*
+NGramScorer scores plaintext by its negative average log-likelihood under
+an n-gram model. Letters are case-folded before counting, and n-grams that
+never appeared in the training corpus get a fixed penalty. Text shorter
+than n gets the worst possible score.
+
+Note that n-grams only make sense for contiguous plaintext; a transposed
+column of a repeating-key XOR ciphertext has no meaningful bigrams.
+*/
+type NGramScorer struct {
+	n        int
+	logProbs map[string]float64
+	floor    float64
+}
+
+// NewNGramScorer trains an n-gram model on corpus.
+func NewNGramScorer(corpus []byte, n int) *NGramScorer {
+	if n < 1 {
+		panic("n needs to be a positive number")
+	}
+	folded := foldASCII(corpus)
+	counts := make(map[string]int)
+	total := 0
+	for i := 0; i+n <= len(folded); i++ {
+		counts[string(folded[i:i+n])]++
+		total++
+	}
+
+	s := &NGramScorer{
+		n:        n,
+		logProbs: make(map[string]float64),
+		floor:    math.Log(0.01 / float64(total+1)),
+	}
+	for gram, count := range counts {
+		s.logProbs[gram] = math.Log(float64(count) / float64(total))
+	}
+	return s
+}
+
+// NewEnglishBigramScorer returns a bigram model trained on English prose.
+func NewEnglishBigramScorer() *NGramScorer {
+	return NewNGramScorer([]byte(englishSample), 2)
+}
+
+// NewEnglishTrigramScorer returns a trigram model trained on English prose.
+func NewEnglishTrigramScorer() *NGramScorer {
+	return NewNGramScorer([]byte(englishSample), 3)
+}
+
+func (s *NGramScorer) Score(pt []byte) float64 {
+	if len(pt) < s.n {
+		return -s.floor
+	}
+	folded := foldASCII(pt)
+	var logLikelihood float64
+	grams := 0
+	for i := 0; i+s.n <= len(folded); i++ {
+		logProb, ok := s.logProbs[string(folded[i:i+s.n])]
+		if !ok {
+			logProb = s.floor
+		}
+		logLikelihood += logProb
+		grams++
+	}
+	return -logLikelihood / float64(grams)
+}
+
+/*
+HistogramScorer scores plaintext by its negative average log-likelihood
+under a byte histogram learned from a corpus. No case folding is done and
+every byte value counts, so this works for any kind of traffic we have
+samples of, not just English.
+*/
+type HistogramScorer struct {
+	logProbs [256]float64
+}
+
+// NewHistogramScorer builds a byte histogram from corpus. Add-one smoothing
+// keeps bytes that never appeared in the corpus from scoring infinitely badly.
+func NewHistogramScorer(corpus []byte) *HistogramScorer {
+	var counts [256]int
+	for _, b := range corpus {
+		counts[b]++
+	}
+	s := &HistogramScorer{}
+	total := float64(len(corpus) + 256)
+	for i, count := range counts {
+		s.logProbs[i] = math.Log(float64(count+1) / total)
+	}
+	return s
+}
+
+// NewHistogramScorerFromFile builds a byte histogram from the contents of
+// a corpus file.
+func NewHistogramScorerFromFile(filename string) (*HistogramScorer, error) {
+	corpus, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return NewHistogramScorer(corpus), nil
+}
+
+func (s *HistogramScorer) Score(pt []byte) float64 {
+	if len(pt) == 0 {
+		return 0
+	}
+	var logLikelihood float64
+	for _, b := range pt {
+		logLikelihood += s.logProbs[b]
+	}
+	return -logLikelihood / float64(len(pt))
+}
@@ -4,6 +4,10 @@ import (
 	"encoding/base64"
 	"encoding/hex"
 	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -148,3 +152,179 @@ func Test_8(t *testing.T) {
 	}
 
 }
+
+func Test_3_Scorers(t *testing.T) {
+	ct, _ := hex.DecodeString("1b37373331363f78151b7f2b783431333d78397828372d363c78373e783a393b3736")
+	scorers := map[string]Scorer{
+		"letter frequency": LetterFrequencyScorer{},
+		"chi-squared":      ChiSquaredScorer{},
+		"bigram":           NewEnglishBigramScorer(),
+		"trigram":          NewEnglishTrigramScorer(),
+		"histogram":        NewHistogramScorer([]byte(englishSample)),
+	}
+	for name, scorer := range scorers {
+		key, pt, _ := BreakSingleByteXOrCipherWithScorer(ct, scorer)
+		if key != 'X' {
+			t.Fatalf("%v scorer picked key %v, plaintext %q", name, key, pt)
+		}
+	}
+
+	//the printable heuristic can't tell the two cases of a letter apart
+	_, pt, _ := BreakSingleByteXOrCipherWithScorer(ct, PrintableScorer{})
+	if !strings.EqualFold(string(pt), "Cooking MC's like a pound of bacon") {
+		t.Fatalf("printable scorer picked plaintext %q", pt)
+	}
+}
+
+func Test_HistogramScorerFromFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "corpus.txt")
+	if err := os.WriteFile(filename, []byte(englishSample), 0600); err != nil {
+		t.Fatal(err)
+	}
+	scorer, err := NewHistogramScorerFromFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scorer.Score([]byte("the cat sat")) >= scorer.Score([]byte{0xff, 0x01, 0x80}) {
+		t.Fatal("English text should score better than binary junk")
+	}
+	if _, err := NewHistogramScorerFromFile(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Fatal("expected an error for a missing corpus file")
+	}
+}
+
+func Test_4_DetectWithScorer(t *testing.T) {
+	lines, _ := ReadFileByLine("input/4.txt")
+	var cts [][]byte
+	for _, line := range lines {
+		bytes, _ := hex.DecodeString(line)
+		cts = append(cts, bytes)
+	}
+	_, key, pt := DetectSingleByteXOr(cts, ChiSquaredScorer{})
+	if string(pt) != "Now that the party is jumping\n" {
+		t.Fatalf("Detected wrong ciphertext: key %v plaintext %q", key, pt)
+	}
+}
+
+func Test_3_Candidates(t *testing.T) {
+	ct, _ := hex.DecodeString("1b37373331363f78151b7f2b783431333d78397828372d363c78373e783a393b3736")
+	candidates := SingleByteXOrCandidates(ct, ChiSquaredScorer{}, 3)
+	if len(candidates) != 3 {
+		t.Fatalf("Expected 3 candidates, got %v", len(candidates))
+	}
+	if candidates[0].Key[0] != 'X' {
+		t.Fatalf("Best candidate has key %v", candidates[0].Key)
+	}
+	for i := 1; i < len(candidates); i++ {
+		if candidates[i].Score < candidates[i-1].Score {
+			t.Fatal("Candidates aren't sorted by score")
+		}
+	}
+}
+
+func Test_6_Candidates(t *testing.T) {
+	lines, err := ReadFileByLine("input/6.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ct []byte
+	for _, l := range lines {
+		dl, _ := base64.StdEncoding.DecodeString(l)
+		ct = append(ct, dl...)
+	}
+	candidates := RepeatingKeyXOrCandidates(ct, 29, 3, 10, LetterFrequencyScorer{})
+	if len(candidates) != 10 {
+		t.Fatalf("Expected 10 candidates, got %v", len(candidates))
+	}
+	if string(candidates[0].Key) != "Terminator X: Bring the noise" {
+		t.Fatalf("Best candidate has key %q", candidates[0].Key)
+	}
+	seen := make(map[string]bool)
+	for i, c := range candidates {
+		if i > 0 && c.Score < candidates[i-1].Score {
+			t.Fatal("Candidates aren't sorted by score")
+		}
+		if seen[string(c.Key)] {
+			t.Fatalf("Key %q returned twice", c.Key)
+		}
+		seen[string(c.Key)] = true
+	}
+}
+
+func Test_6_KeySizeEstimators(t *testing.T) {
+	lines, err := ReadFileByLine("input/6.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ct []byte
+	for _, l := range lines {
+		dl, _ := base64.StdEncoding.DecodeString(l)
+		ct = append(ct, dl...)
+	}
+	estimators := map[string]KeySizeEstimator{
+		"hamming":              HammingKeySizes,
+		"index of coincidence": IndexOfCoincidenceKeySizes,
+		"kasiski":              KasiskiKeySizes,
+	}
+	for name, estimate := range estimators {
+		guesses := estimate(ct, 2, 40)
+		if len(guesses) != 39 {
+			t.Fatalf("%v returned %v guesses", name, len(guesses))
+		}
+		if guesses[0].KeySize != 29 {
+			t.Fatalf("%v estimated key size %v, confidence %v", name, guesses[0].KeySize, guesses[0].Confidence)
+		}
+	}
+
+	guesses := FriedmanKeySizes(ct, 2, 40)
+	var total float64
+	for i, g := range guesses {
+		if i > 0 && g.Confidence > guesses[i-1].Confidence {
+			t.Fatal("Guesses aren't sorted by confidence")
+		}
+		total += g.Confidence
+	}
+	if math.Abs(total-1) > 1e-9 {
+		t.Fatalf("Confidences add up to %v", total)
+	}
+	found := false
+	for _, g := range guesses[:3] {
+		found = found || g.KeySize == 29
+	}
+	if !found {
+		t.Fatalf("Friedman's top guesses %v don't include 29", guesses[:3])
+	}
+}
+
+func Test_6_ShortCiphertext(t *testing.T) {
+	pt := []byte("Burning 'em, if you ain't quick and nimble\nI go crazy when I hear a cymbal")
+	ct := RepeatingKeyXOrCipher(pt, []byte("ICE"))
+	if key := BreakRepeatingKeyXOrCipher(ct); string(key) != "ICE" {
+		t.Fatalf("Recovered key %q", key)
+	}
+
+	//a key too long for 16 bytes per column is still tried
+	long := []byte("Now that the party is jumping\nWith the bass kicked in and the Vegas are pumpin'\n" +
+		"Quick to the point, to the point, no faking\nCooking MCs like a pound of bacon\nBurning 'em, if you ain't quick and nimble\nI go crazy when")
+	ct = RepeatingKeyXOrCipher(long[:200], []byte("thirteen key!"))
+	key := BreakRepeatingKeyXOrCipher(ct)
+	if len(key) != 13 {
+		t.Fatalf("Recovered key %q", key)
+	}
+	//15 bytes a column can leave a column or so wrong
+	right := 0
+	for i, b := range RepeatingKeyXOrCipher(ct, key) {
+		if b == long[i] {
+			right++
+		}
+	}
+	if right < 180 {
+		t.Fatalf("Recovered key %q only decrypts %d of 200 bytes", key, right)
+	}
+	if guesses := HammingKeySizes(ct[:10], 2, 40); len(guesses) != 4 {
+		t.Fatalf("Expected guesses for key sizes 2-5, got %v", guesses)
+	}
+	if size := FindBestXOrKeyLength(ct[:3]); size != 1 {
+		t.Fatalf("Expected a single-byte key for a 3-byte ciphertext, got %v", size)
+	}
+}
@@ -1,7 +1,14 @@
 package cryptopals
 
 import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
 	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"log"
 	"testing"
 )
@@ -49,13 +56,95 @@ func Test_10_Encrypt_Decrypt(t *testing.T) {
 func Test_11(t *testing.T) {
 	log.Print("Problem 11 Testing:")
 	for i := 0; i < 10; i++ {
-		DetectAESOrECB()
+		if !DetectAESOrECB() {
+			t.Fatal("Wrong mode detected")
+		}
+	}
+}
+
+func Test_DetectMode(t *testing.T) {
+	key := Key(16)
+	prefix, suffix := []byte("comment1=cooking%20MCs;userdata="), []byte(";comment2=%20like%20a%20pound")
+	wrap := func(input []byte) []byte {
+		return append(append(append([]byte{}, prefix...), input...), suffix...)
+	}
+	block, _ := aes.NewCipher(key)
+	desBlock, _ := des.NewCipher(Key(8))
+	fixedIV := Key(16)
+	cases := []struct {
+		name          string
+		oracle        func([]byte) []byte
+		mode          CipherMode
+		blockSize     int
+		prefix        int
+		suffix        int
+		deterministic bool
+	}{
+		{"ECB", func(in []byte) []byte {
+			ct, _ := AESInECBModeEncryptWithPadding(wrap(in), key, PKCS7Padding{})
+			return ct
+		}, ModeECB, 16, len(prefix), len(suffix), true},
+		{"DES ECB", func(in []byte) []byte {
+			ct, _ := EncryptWithPadding(NewECBEncrypter(desBlock), PKCS7Padding{}, wrap(in))
+			return ct
+		}, ModeECB, 8, len(prefix), len(suffix), true},
+		{"CBC with a fixed IV", func(in []byte) []byte {
+			ct, _ := AESInCBCModeEncryptWithPadding(wrap(in), key, fixedIV, PKCS7Padding{})
+			return ct
+		}, ModeCBC, 16, len(prefix), len(suffix), true},
+		{"CBC with a random IV", func(in []byte) []byte {
+			ct, _ := AESInCBCModeEncryptWithPadding(wrap(in), key, Key(16), PKCS7Padding{})
+			return ct
+		}, ModeCBC, 16, -1, -1, false},
+		{"CTR with a fixed nonce", func(in []byte) []byte {
+			return CTR_Cipher(wrap(in), key, make([]byte, 8))
+		}, ModeStream, 1, len(prefix), len(suffix), true},
+		{"OFB with a random IV", func(in []byte) []byte {
+			pt := wrap(in)
+			NewOFB(block, Key(16)).XORKeyStream(pt, pt)
+			return pt
+		}, ModeStream, 1, -1, -1, false},
+	}
+	for _, c := range cases {
+		r := DetectMode(c.oracle)
+		if r.Mode != c.mode || r.BlockSize != c.blockSize || r.PrefixLength != c.prefix || r.SuffixLength != c.suffix ||
+			r.Deterministic != c.deterministic || r.Confidence != 1 {
+			t.Fatalf("%v: unexpected report %v", c.name, r)
+		}
+		if r.Overhead != len(prefix)+len(suffix) {
+			t.Fatalf("%v: overhead %v", c.name, r.Overhead)
+		}
+	}
+
+	//CBCOrECBEncrypt picks a new mode every call, which shows up as low
+	//confidence. All the probes can agree by chance, so allow a few tries.
+	sure := 0
+	for i := 0; i < 3; i++ {
+		r := DetectMode(func(in []byte) []byte {
+			ct, _ := CBCOrECBEncryptWithMode(in)
+			return ct
+		})
+		if r.Overhead != -1 || r.PrefixLength != -1 {
+			t.Fatalf("Per-call random oracle gave %v", r)
+		}
+		if r.Confidence == 1 {
+			sure++
+		}
+	}
+	if sure == 3 {
+		t.Fatal("Per-call random mode was detected with full confidence")
+	}
+	if r := DetectMode(func(in []byte) []byte { return nil }); r.Mode != ModeUnknown {
+		t.Fatalf("Empty oracle gave %v", r)
 	}
 }
 
 func Test_12(t *testing.T) {
 	log.Print("Problem 12 Output:")
 	out := AttackECBSuffix()
+	if !bytes.HasPrefix(out, []byte("Rollin' in my 5.0\n")) || !bytes.HasSuffix(out, []byte("I just drove by\n")) {
+		t.Fatalf("Unexpected suffix: %q", out)
+	}
 	log.Print(string(out))
 }
 
@@ -65,11 +154,155 @@ func Test_13(t *testing.T) {
 	}
 }
 
+func Test_Cookie(t *testing.T) {
+	c, err := ParseCookie("foo=bar&baz=qux&zap=zazzle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Cookie{{"foo", "bar"}, {"baz", "qux"}, {"zap", "zazzle"}}
+	if len(c) != len(expected) {
+		t.Fatalf("Parsed %v", c)
+	}
+	for i := range c {
+		if c[i] != expected[i] {
+			t.Fatalf("Parsed %v", c)
+		}
+	}
+
+	//metacharacters survive a round trip without adding fields
+	if p := profileFor("foo@bar.com&role=admin"); p != "email=foo@bar.com%26role%3Dadmin&uid=10&role=user" {
+		t.Fatalf("profileFor gave %q", p)
+	}
+	tricky := Cookie{{"email", "a&b=c%d"}, {"k=", "%"}}
+	parsed, err := ParseCookie(tricky.Encode())
+	if err != nil || len(parsed) != 2 || parsed[0] != tricky[0] || parsed[1] != tricky[1] {
+		t.Fatalf("Round trip gave %v (%v)", parsed, err)
+	}
+	if role, ok := parsed.Get("role"); ok {
+		t.Fatalf("Unexpected role %q", role)
+	}
+
+	for _, bad := range []string{"foo", "foo=bar=baz", "foo=bar&", "foo=%2", "foo=%zz"} {
+		if _, err := ParseCookie(bad); !errors.Is(err, ErrInvalidCookie) {
+			t.Fatalf("Expected ErrInvalidCookie for %q, got %v", bad, err)
+		}
+	}
+}
+
+func Test_ECBCutAndPaste(t *testing.T) {
+	//a layout that doesn't line up the way challenge 13's does, over DES
+	template := Cookie{{"session", "x"}, {"name", ""}, {"group", "staff"}, {"role", "guest"}, {"ts", "1700000000"}}
+	block, _ := des.NewCipher(Key(8))
+	oracle := func(input []byte) []byte {
+		c := append(Cookie{}, template...)
+		c[1].Value = string(input)
+		ct, _ := EncryptWithPadding(NewECBEncrypter(block), PKCS7Padding{}, []byte(c.Encode()))
+		return ct
+	}
+	forged, err := ECBCutAndPaste(oracle, template, "name", "role", "administrator")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pt, err := DecryptWithPadding(NewECBDecrypter(block), PKCS7Padding{}, forged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := ParseCookie(string(pt))
+	if role, _ := c.Get("role"); err != nil || role != "administrator" {
+		t.Fatalf("Forged %q (%v)", pt, err)
+	}
+
+	if _, err := ECBCutAndPaste(oracle, template, "name", "role", "a&b"); !errors.Is(err, ErrInvalidCookie) {
+		t.Fatalf("Expected ErrInvalidCookie, got %v", err)
+	}
+	wrong := Cookie{{"session", "x"}, {"name", ""}, {"role", "guest"}}
+	if _, err := ECBCutAndPaste(oracle, wrong, "name", "role", "admin"); !errors.Is(err, ErrUnexpectedOracle) {
+		t.Fatalf("Expected ErrUnexpectedOracle for the wrong template, got %v", err)
+	}
+}
+
 func Test_14(t *testing.T) {
 	out := AttackECBSuffixWithPrefix()
+	if string(out) != string(AttackECBSuffix()) {
+		t.Fatalf("Unexpected suffix: %q", out)
+	}
 	log.Printf("Problem 14 Output:\n%v", string(out))
 }
 
+func Test_BreakECBSuffix(t *testing.T) {
+	suffix := []byte("attack at dawn, bring snacks\x01")
+	desBlock, _ := des.NewCipher(Key(8))
+	aesBlock, _ := aes.NewCipher(Key(16))
+	cases := []struct {
+		name         string
+		block        cipher.Block
+		prefix       func() []byte
+		prefixLength int
+	}{
+		{"no prefix", aesBlock, func() []byte { return nil }, 0},
+		{"fixed prefix", desBlock, func() []byte { return []byte("user=alice;") }, 11},
+		{"random prefix", aesBlock, func() []byte { return Key(GetRandomInt(40) + 1) }, -1},
+	}
+	for _, c := range cases {
+		oracle := func(input []byte) []byte {
+			pt := append(append(c.prefix(), input...), suffix...)
+			ct, _ := EncryptWithPadding(NewECBEncrypter(c.block), PKCS7Padding{}, pt)
+			return ct
+		}
+		report, err := BreakECBSuffix(oracle)
+		if err != nil {
+			t.Fatalf("%v: %v", c.name, err)
+		}
+		if !bytes.Equal(report.Suffix, suffix) || report.BlockSize != c.block.BlockSize() || report.PrefixLength != c.prefixLength {
+			t.Fatalf("%v: unexpected report %+v", c.name, report)
+		}
+		if report.RandomPrefix != (c.prefixLength < 0) {
+			t.Fatalf("%v: RandomPrefix is %v", c.name, report.RandomPrefix)
+		}
+		log.Printf("%v: %v queries", c.name, report.Queries)
+	}
+
+	//prefixes and suffixes with repeated blocks of their own
+	repeated := append(fill('A', 40), suffix...)
+	zs := func(n int) []byte { return fill('Z', n) }
+	repeats := []struct {
+		name         string
+		prefix       func() []byte
+		suffix       []byte
+		prefixLength int
+	}{
+		{"short prefix, repeated suffix", func() []byte { return []byte("xy") }, repeated, 2},
+		{"repeated prefix", func() []byte { return zs(37) }, suffix, 37},
+		{"repeated prefix and suffix", func() []byte { return zs(64) }, repeated, 64},
+		{"random repeated prefix", func() []byte { return zs(GetRandomInt(60)) }, repeated, -1},
+		{"random prefix with a pattern", func() []byte {
+			return append(append(append(zs(32), fill('Y', 32)...), Key(GetRandomInt(16)+1)...), zs(40)...)
+		}, repeated, -1},
+	}
+	for _, c := range repeats {
+		oracle := func(input []byte) []byte {
+			pt := append(append(c.prefix(), input...), c.suffix...)
+			ct, _ := EncryptWithPadding(NewECBEncrypter(aesBlock), PKCS7Padding{}, pt)
+			return ct
+		}
+		report, err := BreakECBSuffix(oracle)
+		if err != nil {
+			t.Fatalf("%v: %v", c.name, err)
+		}
+		if !bytes.Equal(report.Suffix, c.suffix) || report.PrefixLength != c.prefixLength {
+			t.Fatalf("%v: unexpected report %+v", c.name, report)
+		}
+	}
+
+	cbc := func(input []byte) []byte {
+		ct, _ := AESInCBCModeEncryptWithPadding(append(input, suffix...), Key(16), Key(16), PKCS7Padding{})
+		return ct
+	}
+	if _, err := BreakECBSuffix(cbc); !errors.Is(err, ErrNotECB) {
+		t.Fatalf("Expected ErrNotECB, got %v", err)
+	}
+}
+
 func Test_15(t *testing.T) {
 	out, err := StripPadding([]byte("ICE ICE BABY\x04\x04\x04\x04"))
 	if err != nil {
@@ -90,3 +323,630 @@ func Test_16(t *testing.T) {
 		t.Fatal("Admin account creation not successful")
 	}
 }
+
+func Test_PlanBitflip(t *testing.T) {
+	key, iv := Key(16), Key(16)
+	prefix := []byte("id=7;note=")
+	encrypt := func(input []byte) []byte {
+		pt := append(append([]byte{}, prefix...), bytes.ReplaceAll(input, []byte(";"), []byte("%3B"))...)
+		ct, _ := AESInCBCModeEncryptWithPadding(pt, key, iv, PKCS7Padding{})
+		return ct
+	}
+	decrypt := func(ct []byte) []byte {
+		pt, _ := AESInCBCModeDecryptWithPadding(ct, key, iv, PKCS7Padding{})
+		return pt
+	}
+
+	//a target running over several blocks, with its prefix found by the
+	//planner
+	target := []byte(";admin=true;superuserSince1999")
+	plan, err := PlanBitflipFromOracle(encrypt, ModeCBC, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.ScrambledBlock != 1 || len(plan.Flips) != 3 || plan.Flips[0] != (ByteFlip{16, 'A' ^ ';'}) {
+		t.Fatalf("Unexpected plan %+v", plan)
+	}
+	ct, _ := plan.Apply(encrypt(plan.Input))
+	pt := decrypt(ct)
+	if !bytes.HasSuffix(pt, target) || !bytes.HasPrefix(pt, prefix) {
+		t.Fatalf("Flipped plaintext: %q", pt)
+	}
+
+	//only the filtered bytes are flipped, so spaces after the first block
+	//are fine
+	spaced := []byte(";admin=true;x=1 and a note with spaces")
+	plan, err = PlanBitflip(ModeCBC, len(prefix), 16, spaced)
+	if err != nil || len(plan.Flips) != 4 {
+		t.Fatalf("Unexpected plan %+v (%v)", plan, err)
+	}
+	ct, _ = plan.Apply(encrypt(plan.Input))
+	if pt := decrypt(ct); !bytes.HasSuffix(pt, spaced) {
+		t.Fatalf("Flipped plaintext: %q", pt)
+	}
+	plan, err = PlanBitflipFiltered(ModeCBC, len(prefix), 16, spaced, ";= ")
+	if !errors.Is(err, ErrCannotFlip) {
+		t.Fatalf("Expected ErrCannotFlip when spaces are filtered too, got %+v (%v)", plan, err)
+	}
+
+	//the fixes can't be more than a block apart in CBC, but can in CTR
+	wide := []byte("admin=true&role=root&group=wheel")
+	if _, err := PlanBitflip(ModeCBC, len(prefix), 16, wide); !errors.Is(err, ErrCannotFlip) {
+		t.Fatalf("Expected ErrCannotFlip, got %v", err)
+	}
+	nonce := Key(8)
+	ctr := func(input []byte) []byte {
+		return CTR_Cipher(append(append([]byte{}, prefix...), input...), key, nonce)
+	}
+	plan, err = PlanBitflip(ModeStream, len(prefix), 0, wide)
+	if err != nil || plan.ScrambledBlock != -1 {
+		t.Fatalf("Unexpected plan %+v (%v)", plan, err)
+	}
+	ct, _ = plan.Apply(ctr(plan.Input))
+	if pt := CTR_Cipher(ct, key, nonce); string(pt) != string(prefix)+string(wide) {
+		t.Fatalf("Flipped plaintext: %q", pt)
+	}
+	spaced = []byte(";admin=true; role=x")
+	plan, err = PlanBitflip(ModeStream, len(prefix), 0, spaced)
+	if err != nil || len(plan.Flips) != 4 || !bytes.Contains(plan.Input, []byte(" role")) {
+		t.Fatalf("Unexpected plan %+v (%v)", plan, err)
+	}
+	ct, _ = plan.Apply(ctr(plan.Input))
+	if pt := CTR_Cipher(ct, key, nonce); string(pt) != string(prefix)+string(spaced) {
+		t.Fatalf("Flipped plaintext: %q", pt)
+	}
+	//but its second '=' is past the first block, out of reach in CBC
+	if _, err := PlanBitflip(ModeCBC, len(prefix), 16, spaced); !errors.Is(err, ErrCannotFlip) {
+		t.Fatalf("Expected ErrCannotFlip, got %v", err)
+	}
+
+	if _, err := PlanBitflipFromOracle(ctr, ModeCBC, wide); !errors.Is(err, ErrUnexpectedOracle) {
+		t.Fatalf("Expected ErrUnexpectedOracle for the wrong mode, got %v", err)
+	}
+	if _, err := (BitflipPlan{Flips: []ByteFlip{{100, 1}}}).Apply(ct); !errors.Is(err, ErrInvalidLength) {
+		t.Fatalf("Expected ErrInvalidLength, got %v", err)
+	}
+}
+
+func Test_CheckedErrors(t *testing.T) {
+	key := []byte("YELLOW SUBMARINE")
+	iv := make([]byte, 16)
+	errOf := func(_ interface{}, err error) error { return err }
+	cases := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"XOr", errOf(XOrChecked([]byte("ab"), []byte("abc"))), ErrLengthMismatch},
+		{"BreakIntoBlocks", errOf(BreakIntoBlocksChecked(make([]byte, 17), 16)), ErrNotBlockAligned},
+		{"BreakIntoBlocks size", errOf(BreakIntoBlocksChecked(make([]byte, 16), 0)), ErrInvalidBlockSize},
+		{"Key", errOf(KeyChecked(0)), ErrInvalidLength},
+		{"ECB encrypt", errOf(AESInECBModeEncryptChecked(make([]byte, 15), key)), ErrNotBlockAligned},
+		{"ECB encrypt key", errOf(AESInECBModeEncryptChecked(make([]byte, 16), key[:5])), ErrInvalidKeySize},
+		{"ECB decrypt", errOf(AESInECBModeDecryptChecked(make([]byte, 15), key)), ErrNotBlockAligned},
+		{"ECB decrypt key", errOf(AESInECBModeDecryptChecked(make([]byte, 16), key[:5])), ErrInvalidKeySize},
+		{"CBC encrypt", errOf(AESInCBCModeEncryptChecked(make([]byte, 15), key, iv)), ErrNotBlockAligned},
+		{"CBC encrypt IV", errOf(AESInCBCModeEncryptChecked(make([]byte, 16), key, iv[:8])), ErrInvalidIVSize},
+		{"CBC decrypt", errOf(AESInCBCModeDecryptChecked(make([]byte, 15), key, iv)), ErrNotBlockAligned},
+		{"CBC decrypt key", errOf(AESInCBCModeDecryptChecked(make([]byte, 16), nil, iv)), ErrInvalidKeySize},
+		{"CTR nonce", errOf(CTR_CipherChecked(make([]byte, 16), key, iv)), ErrInvalidNonceSize},
+		{"CTR key", errOf(CTR_CipherChecked(make([]byte, 16), key[:3], iv[:8])), ErrInvalidKeySize},
+	}
+	for _, c := range cases {
+		if !errors.Is(c.err, c.want) {
+			t.Errorf("%v: expected %v, got %v", c.name, c.want, c.err)
+		}
+	}
+
+	ct, err := AESInCBCModeEncryptChecked(Pad([]byte("valid input"), 16), key, iv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pt, err := AESInCBCModeDecryptChecked(ct, key, iv); err != nil || string(pt[:11]) != "valid input" {
+		t.Fatalf("Round trip failed: %q %v", pt, err)
+	}
+}
+
+func Test_BlockModes(t *testing.T) {
+	desBlock, err := des.NewCipher([]byte("8bytekey"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	iv := []byte("initvect")
+	pt := Pad([]byte("a message that spans quite a few DES blocks"), 8)
+
+	//our CBC should agree with the standard library's
+	expected := make([]byte, len(pt))
+	cipher.NewCBCEncrypter(desBlock, iv).CryptBlocks(expected, pt)
+
+	//feed it in pieces to check the IV carries over between calls
+	ct := make([]byte, len(pt))
+	enc := NewCBCEncrypter(desBlock, iv)
+	enc.CryptBlocks(ct[:8], pt[:8])
+	enc.CryptBlocks(ct[8:24], pt[8:24])
+	enc.CryptBlocks(ct[24:], pt[24:])
+	if !bytes.Equal(ct, expected) {
+		t.Fatal("CBC encryption doesn't match crypto/cipher")
+	}
+
+	//decrypt in place, in pieces
+	dec := NewCBCDecrypter(desBlock, iv)
+	dec.CryptBlocks(ct[:16], ct[:16])
+	dec.CryptBlocks(ct[16:], ct[16:])
+	if !bytes.Equal(ct, pt) {
+		t.Fatalf("CBC decryption failed: %q", ct)
+	}
+
+	ecb := make([]byte, len(pt))
+	NewECBEncrypter(desBlock).CryptBlocks(ecb, pt)
+	for i := 0; i < len(pt); i += 8 {
+		block := make([]byte, 8)
+		desBlock.Encrypt(block, pt[i:i+8])
+		if !bytes.Equal(block, ecb[i:i+8]) {
+			t.Fatalf("ECB block %v is wrong", i/8)
+		}
+	}
+	NewECBDecrypter(desBlock).CryptBlocks(ecb, ecb)
+	if !bytes.Equal(ecb, pt) {
+		t.Fatal("ECB decryption failed")
+	}
+}
+
+func decodeHex(t *testing.T, s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// Known-answer tests from NIST SP 800-38A, F.3 and F.4 (AES-128)
+func Test_StreamModes(t *testing.T) {
+	key := decodeHex(t, "2b7e151628aed2a6abf7158809cf4f3c")
+	iv := decodeHex(t, "000102030405060708090a0b0c0d0e0f")
+	pt := decodeHex(t, "6bc1bee22e409f96e93d7e117393172aae2d8a571e03ac9c9eb76fac45af8e51"+
+		"30c81c46a35ce411e5fbc1191a0a52eff69f2445df4f9b17ad2b417be66c3710")
+	block, _ := aes.NewCipher(key)
+
+	cases := []struct {
+		name     string
+		enc, dec func() cipher.Stream
+		pt, ct   []byte
+	}{
+		{
+			"CFB128",
+			func() cipher.Stream { return NewCFBEncrypter(block, iv) },
+			func() cipher.Stream { return NewCFBDecrypter(block, iv) },
+			pt,
+			decodeHex(t, "3b3fd92eb72dad20333449f8e83cfb4ac8a64537a0b3a93fcde3cdad9f1ce58b"+
+				"26751f67a3cbb140b1808cf187a4f4dfc04b05357c5d1c0eeac4c66f9ff7f2e6"),
+		},
+		{
+			"CFB8",
+			func() cipher.Stream { return NewCFB8Encrypter(block, iv) },
+			func() cipher.Stream { return NewCFB8Decrypter(block, iv) },
+			pt[:18],
+			decodeHex(t, "3b79424c9c0dd436bace9e0ed4586a4f32b9"),
+		},
+		{
+			"OFB",
+			func() cipher.Stream { return NewOFB(block, iv) },
+			func() cipher.Stream { return NewOFB(block, iv) },
+			pt,
+			decodeHex(t, "3b3fd92eb72dad20333449f8e83cfb4a7789508d16918f03f53c52dac54ed825"+
+				"9740051e9c5fecf64344f7a82260edcc304c6528f659c77866a510d9c1d6ae5e"),
+		},
+	}
+	for _, c := range cases {
+		//encrypt in uneven pieces to check state carries over between calls
+		ct := make([]byte, len(c.pt))
+		enc := c.enc()
+		enc.XORKeyStream(ct[:5], c.pt[:5])
+		enc.XORKeyStream(ct[5:], c.pt[5:])
+		if !bytes.Equal(ct, c.ct) {
+			t.Fatalf("%v encryption: expected %x, got %x", c.name, c.ct, ct)
+		}
+		c.dec().XORKeyStream(ct, ct)
+		if !bytes.Equal(ct, c.pt) {
+			t.Fatalf("%v decryption: expected %x, got %x", c.name, c.pt, ct)
+		}
+	}
+}
+
+func Test_PCBC(t *testing.T) {
+	block, _ := aes.NewCipher([]byte("YELLOW SUBMARINE"))
+	iv := make([]byte, 16)
+	pt := Pad([]byte("PCBC propagates errors through every following block"), 16)
+
+	ct := make([]byte, len(pt))
+	NewPCBCEncrypter(block, iv).CryptBlocks(ct, pt)
+
+	//the first block is the same as CBC
+	if !bytes.Equal(ct[:16], AESInCBCModeEncrypt(pt[:16], []byte("YELLOW SUBMARINE"), iv)) {
+		t.Fatal("First PCBC block should match CBC")
+	}
+
+	out := make([]byte, len(ct))
+	NewPCBCDecrypter(block, iv).CryptBlocks(out, ct)
+	if !bytes.Equal(out, pt) {
+		t.Fatalf("PCBC round trip failed: %q", out)
+	}
+
+	//flipping a bit in the first block garbles every block after it
+	ct[0] ^= 1
+	NewPCBCDecrypter(block, iv).CryptBlocks(out, ct)
+	for i := 16; i < len(pt); i += 16 {
+		if bytes.Equal(out[i:i+16], pt[i:i+16]) {
+			t.Fatalf("Block %v survived an error in block 0", i/16)
+		}
+	}
+}
+
+// Known-answer tests from IEEE 1619 (the XTS-AES vectors SP 800-38E refers
+// to): vectors 1 and 2 are whole blocks, 15 and 16 need ciphertext stealing.
+func Test_XTS(t *testing.T) {
+	cases := []struct {
+		key1, key2 string
+		sector     uint64
+		pt, ct     string
+	}{
+		{
+			"00000000000000000000000000000000", "00000000000000000000000000000000", 0,
+			"0000000000000000000000000000000000000000000000000000000000000000",
+			"917cf69ebd68b2ec9b9fe9a3eadda692cd43d2f59598ed858c02c2652fbf922e",
+		},
+		{
+			"11111111111111111111111111111111", "22222222222222222222222222222222", 0x3333333333,
+			"4444444444444444444444444444444444444444444444444444444444444444",
+			"c454185e6a16936e39334038acef838bfb186fff7480adc4289382ecd6d394f0",
+		},
+		{
+			"fffefdfcfbfaf9f8f7f6f5f4f3f2f1f0", "bfbebdbcbbbab9b8b7b6b5b4b3b2b1b0", 0x123456789a,
+			"000102030405060708090a0b0c0d0e0f10",
+			"6c1625db4671522d3d7599601de7ca09ed",
+		},
+		{
+			"fffefdfcfbfaf9f8f7f6f5f4f3f2f1f0", "bfbebdbcbbbab9b8b7b6b5b4b3b2b1b0", 0x123456789a,
+			"000102030405060708090a0b0c0d0e0f1011",
+			"d069444b7a7e0cab09e24447d24deb1fedbf",
+		},
+	}
+	for i, c := range cases {
+		k1, _ := aes.NewCipher(decodeHex(t, c.key1))
+		k2, _ := aes.NewCipher(decodeHex(t, c.key2))
+		xts, err := NewXTS(k1, k2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pt := decodeHex(t, c.pt)
+		ct := make([]byte, len(pt))
+		if err := xts.Encrypt(ct, pt, c.sector); err != nil {
+			t.Fatal(err)
+		}
+		if hex.EncodeToString(ct) != c.ct {
+			t.Fatalf("XTS case %v: expected %v, got %x", i, c.ct, ct)
+		}
+		if err := xts.Decrypt(ct, ct, c.sector); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(ct, pt) {
+			t.Fatalf("XTS case %v decryption: got %x", i, ct)
+		}
+	}
+
+	k, _ := aes.NewCipher(make([]byte, 16))
+	xts, _ := NewXTS(k, k)
+	if err := xts.Encrypt(make([]byte, 15), make([]byte, 15), 0); !errors.Is(err, ErrInvalidLength) {
+		t.Fatalf("Expected ErrInvalidLength for a short sector, got %v", err)
+	}
+	d, _ := des.NewCipher([]byte("8bytekey"))
+	if _, err := NewXTS(d, d); !errors.Is(err, ErrUnsupportedBlockSize) {
+		t.Fatalf("Expected ErrUnsupportedBlockSize for DES, got %v", err)
+	}
+}
+
+// Test cases 1-6 from the GCM specification, which NIST publishes as its
+// AES-128 GCM test vectors.
+func Test_GCM(t *testing.T) {
+	p := "d9313225f88406e5a55909c5aff5269a86a7a9531534f7da2e4c303d8a318a72" +
+		"1c3c0c95956809532fcf0e2449a6b525b16aedf5aa0de657ba637b391aafd255"
+	k := "feffe9928665731c6d6a8f9467308308"
+	aad := "feedfacedeadbeeffeedfacedeadbeefabaddad2"
+	cases := []struct {
+		key, nonce, pt, aad, ct, tag string
+	}{
+		{"00000000000000000000000000000000", "000000000000000000000000", "", "", "",
+			"58e2fccefa7e3061367f1d57a4e7455a"},
+		{"00000000000000000000000000000000", "000000000000000000000000",
+			"00000000000000000000000000000000", "", "0388dace60b6a392f328c2b971b2fe78",
+			"ab6e47d42cec13bdf53a67b21257bddf"},
+		{k, "cafebabefacedbaddecaf888", p, "",
+			"42831ec2217774244b7221b784d0d49ce3aa212f2c02a4e035c17e2329aca12e" +
+				"21d514b25466931c7d8f6a5aac84aa051ba30b396a0aac973d58e091473f5985",
+			"4d5c2af327cd64a62cf35abd2ba6fab4"},
+		{k, "cafebabefacedbaddecaf888", p[:120], aad,
+			"42831ec2217774244b7221b784d0d49ce3aa212f2c02a4e035c17e2329aca12e" +
+				"21d514b25466931c7d8f6a5aac84aa051ba30b396a0aac973d58e091",
+			"5bc94fbc3221a5db94fae95ae7121a47"},
+		{k, "cafebabefacedbad", p[:120], aad,
+			"61353b4c2806934a777ff51fa22a4755699b2a714fcdc6f83766e5f97b6c7423" +
+				"73806900e49f24b22b097544d4896b424989b5e1ebac0f07c23f4598",
+			"3612d2e79e3b0785561be14aaca2fccb"},
+		{k, "9313225df88406e555909c5aff5269aa6a7a9538534f7da1e4c303d2a318a728" +
+			"c3c0c95156809539fcf0e2429a6b525416aedbf5a0de6a57a637b39b", p[:120], aad,
+			"8ce24998625615b603a033aca13fb894be9112a5c3a211a8ba262a3cca7e2ca7" +
+				"01e4a9a4fba43c90ccdcb281d48c7c6fd62875d2aca417034c34aee5",
+			"619cc5aefffe0bfa462af43c1699d050"},
+	}
+	for i, c := range cases {
+		g, err := NewAESGCM(decodeHex(t, c.key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		nonce := decodeHex(t, c.nonce)
+		sealed, err := g.Seal(nonce, decodeHex(t, c.pt), decodeHex(t, c.aad))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := c.ct + c.tag; hex.EncodeToString(sealed) != expected {
+			t.Fatalf("GCM case %v: expected %v, got %x", i+1, expected, sealed)
+		}
+		pt, err := g.Open(nonce, sealed, decodeHex(t, c.aad))
+		if err != nil || hex.EncodeToString(pt) != c.pt {
+			t.Fatalf("GCM case %v: open returned %x, %v", i+1, pt, err)
+		}
+		sealed[0] ^= 1
+		if _, err := g.Open(nonce, sealed, decodeHex(t, c.aad)); !errors.Is(err, ErrAuthenticationFailed) {
+			t.Fatalf("GCM case %v: tampered message wasn't rejected", i+1)
+		}
+	}
+}
+
+func Test_GCMMatchesStandardLibrary(t *testing.T) {
+	key := Key(16)
+	block, _ := aes.NewCipher(key)
+	std, _ := cipher.NewGCMWithTagSize(block, 12)
+	g, err := NewAESGCMWithTagSize(key, 12)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 20; i++ {
+		nonce := Key(12)
+		pt := Key(GetRandomInt(100) + 1)
+		aad := Key(GetRandomInt(40) + 1)
+		sealed, _ := g.Seal(nonce, pt, aad)
+		if !bytes.Equal(sealed, std.Seal(nil, nonce, pt, aad)) {
+			t.Fatalf("Output differs from crypto/cipher for a %v byte message", len(pt))
+		}
+	}
+
+	if _, err := NewAESGCMWithTagSize(key, 3); !errors.Is(err, ErrInvalidTagSize) {
+		t.Fatalf("Expected ErrInvalidTagSize, got %v", err)
+	}
+}
+
+// Intermediate values from test cases 2 and 4 of the GCM specification
+func Test_GHASH(t *testing.T) {
+	block := func(s string) GF128 { return GF128FromBytes(decodeHex(t, s)) }
+
+	//test case 2: a single block, so X1 = C * H is a multiplication on its own
+	g, _ := NewAESGCM(make([]byte, 16))
+	h := g.H()
+	if h != block("66e94bd4ef8a2c3b884cfa59ca342b2e") {
+		t.Fatalf("H is %x", h.Bytes())
+	}
+	ct := decodeHex(t, "0388dace60b6a392f328c2b971b2fe78")
+	if x := GF128FromBytes(ct).Mul(h); x != block("5e2ec746917062882c85b0685353deb7") {
+		t.Fatalf("X1 is %x", x.Bytes())
+	}
+	if y := GHASH(h, nil, ct); y != block("f38cbb1ad69223dcc3457ae5b6b0f885") {
+		t.Fatalf("GHASH is %x", y.Bytes())
+	}
+
+	//test case 4: additional data, and partial final blocks
+	g, _ = NewAESGCM(decodeHex(t, "feffe9928665731c6d6a8f9467308308"))
+	if g.H() != block("b83b533708bf535d0aa6e52980d53b78") {
+		t.Fatalf("H is %x", g.H().Bytes())
+	}
+	aad := decodeHex(t, "feedfacedeadbeeffeedfacedeadbeefabaddad2")
+	ct = decodeHex(t, "42831ec2217774244b7221b784d0d49ce3aa212f2c02a4e035c17e2329aca12e"+
+		"21d514b25466931c7d8f6a5aac84aa051ba30b396a0aac973d58e091")
+	if y := GHASH(g.H(), aad, ct); y != block("698e57f70e6ecc7fd9463b7260a9ae5f") {
+		t.Fatalf("GHASH is %x", y.Bytes())
+	}
+}
+
+func Test_Paddings(t *testing.T) {
+	schemes := []struct {
+		padding Padding
+		padded  string
+	}{
+		{PKCS7Padding{}, "ICE ICE BABY\x04\x04\x04\x04"},
+		{ANSIX923Padding{}, "ICE ICE BABY\x00\x00\x00\x04"},
+		{ISO7816Padding{}, "ICE ICE BABY\x80\x00\x00\x00"},
+		{ZeroPadding{}, "ICE ICE BABY\x00\x00\x00\x00"},
+	}
+	in := []byte("ICE ICE BABY")
+	for _, s := range schemes {
+		out, err := s.padding.Pad(in, 16)
+		if err != nil || string(out) != s.padded {
+			t.Fatalf("%T: expected %q, got %q (%v)", s.padding, s.padded, out, err)
+		}
+		stripped, err := s.padding.Unpad(out, 16)
+		if err != nil || string(stripped) != string(in) {
+			t.Fatalf("%T: unpad returned %q (%v)", s.padding, stripped, err)
+		}
+	}
+
+	//ISO 10126 has random filler, so only the length byte is fixed
+	out, _ := ISO10126Padding{}.Pad(in, 8)
+	if len(out) != 16 || out[15] != 4 {
+		t.Fatalf("ISO 10126 padding error: %q", out)
+	}
+	if stripped, err := (ISO10126Padding{}).Unpad(out, 8); err != nil || string(stripped) != string(in) {
+		t.Fatalf("ISO 10126 unpad returned %q (%v)", stripped, err)
+	}
+
+	//a full block of padding is added to aligned input, except by zero
+	//padding and no padding
+	aligned := []byte("YELLOW SUBMARINE")
+	for _, p := range []Padding{PKCS7Padding{}, ANSIX923Padding{}, ISO10126Padding{}, ISO7816Padding{}} {
+		if out, _ := p.Pad(aligned, 16); len(out) != 32 {
+			t.Fatalf("%T added %v bytes to aligned input", p, len(out)-16)
+		}
+	}
+	if out, _ := (NoPadding{}).Pad(aligned, 16); string(out) != string(aligned) {
+		t.Fatal("NoPadding changed aligned input")
+	}
+	if _, err := (NoPadding{}).Pad(in, 16); !errors.Is(err, ErrNotBlockAligned) {
+		t.Fatalf("Expected ErrNotBlockAligned, got %v", err)
+	}
+
+	invalid := []struct {
+		padding Padding
+		input   string
+	}{
+		{PKCS7Padding{}, "ICE ICE BABY\x05\x05\x05\x05"},
+		{PKCS7Padding{}, "ICE ICE BABY\x01\x02\x03\x04"},
+		{PKCS7Padding{}, "ICE ICE BABY\x04\x04\x04\x00"},
+		{PKCS7Padding{}, ""},
+		{ANSIX923Padding{}, "ICE ICE BABY\x00\x01\x00\x04"},
+		{ANSIX923Padding{}, "ICE ICE BABY\x00\x00\x00\x11"},
+		{ISO10126Padding{}, "ICE ICE BABY\x00\x00\x00\x00"},
+		{ISO7816Padding{}, "ICE ICE BABY\x80\x00\x01\x00"},
+		{ISO7816Padding{}, "ICE ICE BABY\x00\x00\x00\x00"},
+	}
+	for _, c := range invalid {
+		_, err := c.padding.Unpad([]byte(c.input), 16)
+		var perr *PaddingError
+		if !errors.As(err, &perr) || !errors.Is(err, ErrInvalidPadding) {
+			t.Fatalf("%T accepted %q (%v)", c.padding, c.input, err)
+		}
+	}
+	if _, err := (PKCS7Padding{}).Unpad([]byte("ICE ICE BABY\x01"), 16); !errors.Is(err, ErrNotBlockAligned) {
+		t.Fatalf("Expected ErrNotBlockAligned, got %v", err)
+	}
+	if _, err := (PKCS7Padding{}).Pad(in, 256); !errors.Is(err, ErrInvalidBlockSize) {
+		t.Fatalf("Expected ErrInvalidBlockSize, got %v", err)
+	}
+}
+
+func Test_PadDoesNotAlias(t *testing.T) {
+	buf := make([]byte, 12, 32)
+	copy(buf, "ICE ICE BABY")
+	padded := Pad(buf[:12], 16)
+	padded[0] = 'X'
+	if buf[0] != 'I' || buf[:13][12] != 0 {
+		t.Fatal("Pad modified the caller's buffer")
+	}
+	if _, err := StripPadding(nil); !errors.Is(err, ErrInvalidPadding) {
+		t.Fatalf("Expected ErrInvalidPadding for empty input, got %v", err)
+	}
+}
+
+func Test_BlockModesWithPadding(t *testing.T) {
+	key, iv := Key(16), Key(16)
+	pt := []byte("padding is chosen by the caller")
+	for _, p := range []Padding{PKCS7Padding{}, ANSIX923Padding{}, ISO10126Padding{}, ISO7816Padding{}} {
+		ct, err := AESInCBCModeEncryptWithPadding(pt, key, iv, p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := AESInCBCModeDecryptWithPadding(ct, key, iv, p)
+		if err != nil || string(out) != string(pt) {
+			t.Fatalf("%T: CBC round trip returned %q (%v)", p, out, err)
+		}
+		ct, _ = AESInECBModeEncryptWithPadding(pt, key, p)
+		if out, err = AESInECBModeDecryptWithPadding(ct, key, p); err != nil || string(out) != string(pt) {
+			t.Fatalf("%T: ECB round trip returned %q (%v)", p, out, err)
+		}
+	}
+
+	//same thing over DES in PCBC mode
+	block, _ := des.NewCipher(Key(8))
+	desIV := Key(8)
+	ct, err := EncryptWithPadding(NewPCBCEncrypter(block, desIV), ISO7816Padding{}, pt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := DecryptWithPadding(NewPCBCDecrypter(block, desIV), ISO7816Padding{}, ct)
+	if err != nil || string(out) != string(pt) {
+		t.Fatalf("PCBC round trip returned %q (%v)", out, err)
+	}
+	if _, err := DecryptWithPadding(NewPCBCDecrypter(block, desIV), PKCS7Padding{}, ct); !errors.Is(err, ErrInvalidPadding) {
+		t.Fatalf("Expected ErrInvalidPadding for the wrong scheme, got %v", err)
+	}
+}
+
+func Test_StripPaddingConstantTime(t *testing.T) {
+	//agrees with StripPadding on every last block made of a few byte values
+	values := []byte{0, 1, 2, 3, 4, 'A'}
+	block := make([]byte, 4)
+	var try func(i int)
+	try = func(i int) {
+		if i == len(block) {
+			in := append([]byte("ICE ICE BABY"), block...)
+			expected, expectedErr := StripPadding(in)
+			actual, err := StripPaddingConstantTime(in, 4)
+			if (err == nil) != (expectedErr == nil) || string(actual) != string(expected) {
+				t.Fatalf("Disagreement on %q: %q (%v), expected %q (%v)", in, actual, err, expected, expectedErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidPadding) {
+				t.Fatalf("Expected ErrInvalidPadding, got %v", err)
+			}
+			return
+		}
+		for _, v := range values {
+			block[i] = v
+			try(i + 1)
+		}
+	}
+	try(0)
+
+	if _, err := StripPaddingConstantTime(nil, 16); !errors.Is(err, ErrInvalidPadding) {
+		t.Fatalf("Expected ErrInvalidPadding for empty input, got %v", err)
+	}
+	if _, err := StripPaddingConstantTime([]byte("ICE ICE BABY\x01"), 16); !errors.Is(err, ErrNotBlockAligned) {
+		t.Fatalf("Expected ErrNotBlockAligned, got %v", err)
+	}
+}
+
+func Test_PaddingTimingHarness(t *testing.T) {
+	leaky := func(b []byte) ([]byte, error) { return StripPadding(b) }
+	fixed := func(b []byte) ([]byte, error) { return StripPaddingConstantTime(b, 16) }
+	for _, unpad := range []struct {
+		name string
+		f    func([]byte) ([]byte, error)
+	}{{"StripPadding", leaky}, {"StripPaddingConstantTime", fixed}} {
+		report, err := MeasureUnpadTiming(unpad.f, 16, 20, 200)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(report.Lengths) != 16 || report.Lengths[15].PaddingLength != 16 || report.Lengths[0].Samples != 20 {
+			t.Fatalf("Unexpected report shape: %+v", report.Lengths)
+		}
+		log.Printf("Timing of %v by padding length:\n%v", unpad.name, report)
+	}
+	if _, err := MeasureUnpadTiming(leaky, 16, 1, 1); !errors.Is(err, ErrInvalidLength) {
+		t.Fatalf("Expected ErrInvalidLength, got %v", err)
+	}
+}
+
+func benchmarkUnpad(b *testing.B, unpad func([]byte) ([]byte, error)) {
+	for _, n := range []int{1, 8, 16} {
+		in := Pad(make([]byte, 32-n), 16)
+		b.Run(fmt.Sprintf("padding=%v", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				unpad(in)
+			}
+		})
+	}
+}
+
+func Benchmark_StripPadding(b *testing.B) {
+	benchmarkUnpad(b, StripPadding)
+}
+
+func Benchmark_StripPaddingConstantTime(b *testing.B) {
+	benchmarkUnpad(b, func(in []byte) ([]byte, error) { return StripPaddingConstantTime(in, 16) })
+}
@@ -1,13 +1,202 @@
 package cryptopals
 
 import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
 	"encoding/base64"
+	"errors"
 	"log"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
 func Test_17(t *testing.T) {
-	log.Printf("17 output:\n%v", string(CBCPaddingOracle()))
+	out := CBCPaddingOracle()
+	if !bytes.HasPrefix(out, []byte("00000")) {
+		t.Fatalf("Unexpected plaintext: %q", out)
+	}
+	log.Printf("17 output:\n%v", string(out))
+}
+
+// identityBlock is a "block cipher" that does nothing, so tests can choose
+// exactly what a block decrypts to.
+type identityBlock int
+
+func (b identityBlock) BlockSize() int          { return int(b) }
+func (b identityBlock) Encrypt(dst, src []byte) { copy(dst, src[:b]) }
+func (b identityBlock) Decrypt(dst, src []byte) { copy(dst, src[:b]) }
+
+func cbcPaddingOracle(b cipher.Block) PaddingOracle {
+	return func(iv, ct []byte) bool {
+		_, err := DecryptWithPadding(NewCBCDecrypter(b, iv), PKCS7Padding{}, ct)
+		return err == nil
+	}
+}
+
+func Test_BreakPaddingOracle(t *testing.T) {
+	key := Key(16)
+	block, _ := aes.NewCipher(key)
+	for _, n := range []int{0, 1, 15, 16, 17, 40} {
+		pt := Key(n + 1)[:n]
+		iv := Key(16)
+		ct, _ := AESInCBCModeEncryptWithPadding(pt, key, iv, PKCS7Padding{})
+		out, queries, err := BreakPaddingOracle(cbcPaddingOracle(block), 16, iv, ct)
+		if err != nil || !bytes.Equal(out, pt) {
+			t.Fatalf("%v byte message: got %q (%v)", n, out, err)
+		}
+		if blocks := len(ct) / 16; queries > blocks*(256*16+1) {
+			t.Fatalf("%v queries for %v blocks", queries, blocks)
+		}
+	}
+
+	/*
+		Under the identity cipher a block decrypts to itself XOR the forged
+		block, so a second-to-last byte of 0x02 makes forging the last byte
+		to 0x02 look valid as well as 0x01. Whether the false hit comes up
+		first depends on the last byte, so try them all. The same goes for
+		\x03\x03 and so on.
+	*/
+	for _, tail := range []string{"\x02", "\x03\x03", "\x07\x07\x07\x07\x07\x07"} {
+		for last := 0; last < 256; last++ {
+			ct := []byte("dawn!!!!")
+			copy(ct[7-len(tail):], tail)
+			ct[7] = byte(last)
+			//the real plaintext ends in \x01
+			iv := []byte("ATTACK\x00\x00")
+			iv[7] = ct[7] ^ 1
+			pt := make([]byte, 8)
+			NewCBCDecrypter(identityBlock(8), iv).CryptBlocks(pt, ct)
+			expected, err := StripPadding(pt)
+			out, _, attackErr := BreakPaddingOracle(cbcPaddingOracle(identityBlock(8)), 8, iv, ct)
+			if err != nil || attackErr != nil || !bytes.Equal(out, expected) {
+				t.Fatalf("Tail %q, last byte %v: got %q (%v), expected %q (%v)", tail, last, out, attackErr, expected, err)
+			}
+		}
+	}
+
+	never := func(iv, ct []byte) bool { return false }
+	if _, queries, err := BreakPaddingOracle(never, 16, Key(16), Key(16)); !errors.Is(err, ErrPaddingOracleFailed) || queries != 256 {
+		t.Fatalf("Expected ErrPaddingOracleFailed after 256 queries, got %v after %v", err, queries)
+	}
+	if _, _, err := BreakPaddingOracle(never, 16, Key(16), Key(20)); !errors.Is(err, ErrNotBlockAligned) {
+		t.Fatalf("Expected ErrNotBlockAligned, got %v", err)
+	}
+}
+
+func Test_ForgeWithPaddingOracle(t *testing.T) {
+	key := Key(16)
+	block, _ := aes.NewCipher(key)
+	pt := []byte(";admin=true;role=root;expires=never")
+	iv, ct, queries, err := ForgeWithPaddingOracle(cbcPaddingOracle(block), 16, pt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := AESInCBCModeDecryptWithPadding(ct, key, iv, PKCS7Padding{})
+	if err != nil || !bytes.Equal(out, pt) {
+		t.Fatalf("Forgery decrypted to %q (%v)", out, err)
+	}
+	log.Printf("Forged %v blocks in %v queries", len(ct)/16, queries)
+
+	never := func(iv, ct []byte) bool { return false }
+	if _, _, _, err := ForgeWithPaddingOracle(never, 16, pt); !errors.Is(err, ErrPaddingOracleFailed) {
+		t.Fatalf("Expected ErrPaddingOracleFailed, got %v", err)
+	}
+}
+
+func Test_MeteredOracle(t *testing.T) {
+	//record a run against the real oracle
+	transcript := filepath.Join(t.TempDir(), "transcript.jsonl")
+	f, err := os.Create(transcript)
+	if err != nil {
+		t.Fatal(err)
+	}
+	metered := NewMeteredOracle(ECBWithUnknownSuffix())
+	metered.Record(f)
+	report, err := BreakECBSuffixChecked(metered.Query, DefaultECBRetries)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metered.Queries != report.Queries || metered.MeanLatency() <= 0 || metered.MaxLatency < metered.MeanLatency() {
+		t.Fatalf("Counted %v queries (attack counted %v), mean latency %v, max %v",
+			metered.Queries, report.Queries, metered.MeanLatency(), metered.MaxLatency)
+	}
+
+	//replaying it gives the same answer without the key
+	exchanges, err := LoadTranscript(transcript)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(exchanges) != metered.Queries {
+		t.Fatalf("Transcript has %v exchanges, expected %v", len(exchanges), metered.Queries)
+	}
+	replayed := NewMeteredCheckedOracle(ReplayOracleChecked(exchanges))
+	replayReport, err := BreakECBSuffixChecked(replayed.Query, DefaultECBRetries)
+	if err != nil || !bytes.Equal(replayReport.Suffix, report.Suffix) {
+		t.Fatalf("Replay gave %q (%v)", replayReport.Suffix, err)
+	}
+
+	//a query that wasn't recorded
+	if _, err := replayed.Query([]byte("something new")); !errors.Is(err, ErrNotInTranscript) {
+		t.Fatalf("Expected ErrNotInTranscript, got %v", err)
+	}
+	err = Run(func() error {
+		ReplayOracle(exchanges)([]byte("something new"))
+		return nil
+	})
+	if !errors.Is(err, ErrNotInTranscript) {
+		t.Fatalf("Expected ErrNotInTranscript from Run, got %v", err)
+	}
+
+	//a budget stops the attack part way through
+	limited := NewMeteredOracle(ECBWithUnknownSuffix())
+	limited.MaxQueries = 100
+	_, err = BreakECBSuffixChecked(limited.Query, DefaultECBRetries)
+	var budgetErr *QueryBudgetError
+	if !errors.As(err, &budgetErr) || !errors.Is(err, ErrQueryBudgetExceeded) || limited.Queries != 100 {
+		t.Fatalf("Expected a budget error after 100 queries, got %v after %v", err, limited.Queries)
+	}
+
+	//Func does the same inside Run
+	limited = NewMeteredOracle(ECBWithUnknownSuffix())
+	limited.MaxQueries = 100
+	err = Run(func() error {
+		_, err := BreakECBSuffix(limited.Func())
+		return err
+	})
+	if !errors.Is(err, ErrQueryBudgetExceeded) || limited.Queries != 100 {
+		t.Fatalf("Expected a budget error from Run after 100 queries, got %v after %v", err, limited.Queries)
+	}
+
+	//and panics without it
+	limited = NewMeteredOracle(ECBWithUnknownSuffix())
+	limited.MaxQueries = 100
+	func() {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Fatal("Expected Func to panic without Run")
+			}
+		}()
+		BreakECBSuffix(limited.Func())
+	}()
+
+	//padding oracles are metered through an adapter
+	key, iv := Key(16), Key(16)
+	block, _ := aes.NewCipher(key)
+	ct, _ := AESInCBCModeEncryptWithPadding([]byte("metered padding oracle"), key, iv, PKCS7Padding{})
+	paddingMeter := NewMeteredOracle(PaddingOracleBytes(cbcPaddingOracle(block), 16))
+	out, queries, err := BreakPaddingOracleChecked(paddingMeter.CheckedPaddingOracle(), 16, iv, ct)
+	if err != nil || queries != paddingMeter.Queries || string(out) != "metered padding oracle" {
+		t.Fatalf("Attack counted %v queries, meter counted %v (%q, %v)", queries, paddingMeter.Queries, out, err)
+	}
+	paddingMeter.MaxQueries = paddingMeter.Queries + 10
+	if _, _, _, err := ForgeWithPaddingOracleChecked(paddingMeter.CheckedPaddingOracle(), 16, out); !errors.Is(err, ErrQueryBudgetExceeded) {
+		t.Fatalf("Expected a budget error from the forgery, got %v", err)
+	}
 }
 
 func Test_18(t *testing.T) {
@@ -17,7 +206,158 @@ func Test_18(t *testing.T) {
 	}
 	key := []byte("YELLOW SUBMARINE")
 	nonce := make([]byte, 8)
-	log.Printf("18 output:\n%v", string(CTR_Cipher(ciphertext, key, nonce)))
+	pt := CTR_Cipher(ciphertext, key, nonce)
+	if !bytes.HasPrefix(pt, []byte("Yo, VIP Let's kick it Ice, Ice, baby Ice, Ice, baby")) {
+		t.Fatalf("Unexpected plaintext: %q", pt)
+	}
+	log.Printf("18 output:\n%v", string(pt))
+}
+
+// Known-answer test from NIST SP 800-38A, F.5.1 and F.5.2 (AES-128)
+func Test_CTRNIST(t *testing.T) {
+	key := decodeHex(t, "2b7e151628aed2a6abf7158809cf4f3c")
+	iv := decodeHex(t, "f0f1f2f3f4f5f6f7f8f9fafbfcfdfeff")
+	pt := decodeHex(t, "6bc1bee22e409f96e93d7e117393172aae2d8a571e03ac9c9eb76fac45af8e51"+
+		"30c81c46a35ce411e5fbc1191a0a52eff69f2445df4f9b17ad2b417be66c3710")
+	ct := decodeHex(t, "874d6191b620e3261bef6864990db6ce9806f66b7970fdff8617187bb9fffdff"+
+		"5ae4df3edbd5d35e5b4f09020db03eab1e031dda2fbe03d1792170a0f3009cee")
+	block, _ := aes.NewCipher(key)
+
+	ctr, err := NewCTR(block, iv, CTRLayoutNIST)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := make([]byte, len(pt))
+	ctr.XORKeyStream(out, pt)
+	if !bytes.Equal(out, ct) {
+		t.Fatalf("Encrypted to %x, expected %x", out, ct)
+	}
+
+	//decrypt in uneven pieces, in place
+	ctr, _ = NewCTR(block, iv, CTRLayoutNIST)
+	out = append([]byte{}, ct...)
+	for i, n := 0, 1; i < len(out); i, n = i+n, n+6 {
+		end := i + n
+		if end > len(out) {
+			end = len(out)
+		}
+		ctr.XORKeyStream(out[i:end], out[i:end])
+	}
+	if !bytes.Equal(out, pt) {
+		t.Fatalf("Decrypted to %x, expected %x", out, pt)
+	}
+}
+
+func Test_CTRLayouts(t *testing.T) {
+	key := Key(16)
+	block, _ := aes.NewCipher(key)
+	msg := make([]byte, 100)
+	stream := func(ctr *CTR, err error) []byte {
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := make([]byte, len(msg))
+		ctr.XORKeyStream(out, msg)
+		return out
+	}
+
+	//the standard library's CTR is a 128-bit big-endian counter
+	iv := Key(16)
+	expected := make([]byte, len(msg))
+	cipher.NewCTR(block, iv).XORKeyStream(expected, msg)
+	if out := stream(NewCTR(block, iv, CTRLayoutNIST)); !bytes.Equal(out, expected) {
+		t.Fatal("128-bit counter doesn't match crypto/cipher")
+	}
+
+	//a 128-bit counter carries past the low 64 bits
+	iv = decodeHex(t, "0000000000000000ffffffffffffffff")
+	cipher.NewCTR(block, iv).XORKeyStream(expected, msg)
+	if out := stream(NewCTRWithNonce(block, nil, math.MaxUint64, CTRLayoutNIST)); !bytes.Equal(out, expected) {
+		t.Fatal("128-bit counter doesn't carry")
+	}
+
+	//challenge 18's layout, starting somewhere other than 0
+	nonce := Key(8)
+	ctr, _ := NewCTRWithNonce(block, nonce, 0, CTRLayoutChallenge18)
+	skipped := make([]byte, 3*16)
+	ctr.XORKeyStream(skipped, skipped)
+	if out := stream(NewCTRWithNonce(block, nonce, 3, CTRLayoutChallenge18)); !bytes.Equal(out, stream(ctr, nil)) {
+		t.Fatal("Starting counter doesn't match skipping keystream")
+	}
+
+	//a 32-bit big-endian counter with wrapping allowed is GCM's inc32
+	gcmNonce := Key(12)
+	j0 := append(append([]byte{}, gcmNonce...), 0xff, 0xff, 0xff, 0xfe)
+	expected = XOr(msg, AESInECBModeEncrypt(Flatten(GCMCounterBlocks(j0, 7)), key)[:len(msg)])
+	wrapping := CounterLayout{CounterBits: 32, AllowWrap: true}
+	if out := stream(NewCTRWithNonce(block, gcmNonce, 0xffffffff, wrapping)); !bytes.Equal(out, expected) {
+		t.Fatal("32-bit counter doesn't match inc32")
+	}
+
+	//little-endian 32-bit: the counter block after ff ff ff ff is 00 00 00 00
+	ctr, _ = NewCTRWithNonce(block, gcmNonce, 0xffffffff, CounterLayout{CounterBits: 32, LittleEndian: true, AllowWrap: true})
+	ctr.XORKeyStream(make([]byte, 16), make([]byte, 16))
+	if c := ctr.Counter(); !bytes.Equal(c, append(append([]byte{}, gcmNonce...), 0, 0, 0, 0)) {
+		t.Fatalf("Counter block %x after wrapping", c)
+	}
+}
+
+func Test_CTRWrap(t *testing.T) {
+	block, _ := aes.NewCipher(Key(16))
+	nonce := Key(12)
+
+	//two blocks are left before a 32-bit counter at 0xfffffffe wraps
+	ctr, _ := NewCTRWithNonce(block, nonce, 0xfffffffe, CTRLayoutRFC3686)
+	buf := make([]byte, 33)
+	if err := ctr.XORKeyStreamChecked(buf, buf); !errors.Is(err, ErrCounterWrapped) {
+		t.Fatalf("Expected ErrCounterWrapped, got %v", err)
+	}
+	if !bytes.Equal(buf, make([]byte, 33)) {
+		t.Fatal("Output written on error")
+	}
+	if err := ctr.XORKeyStreamChecked(buf[:20], buf[:20]); err != nil {
+		t.Fatal(err)
+	}
+	if err := ctr.XORKeyStreamChecked(buf[20:32], buf[20:32]); err != nil {
+		t.Fatal(err)
+	}
+	if err := ctr.XORKeyStreamChecked(buf[32:], buf[32:]); !errors.Is(err, ErrCounterWrapped) {
+		t.Fatalf("Expected ErrCounterWrapped, got %v", err)
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("XORKeyStream didn't panic on wrap")
+			}
+		}()
+		ctr.XORKeyStream(buf[32:], buf[32:])
+	}()
+
+	//a 64-bit little-endian counter at its largest value has one block left
+	ctr, _ = NewCTRWithNonce(block, Key(8), math.MaxUint64, CTRLayoutChallenge18)
+	if err := ctr.XORKeyStreamChecked(buf[:16], buf[:16]); err != nil {
+		t.Fatal(err)
+	}
+	if err := ctr.XORKeyStreamChecked(buf[:1], buf[:1]); !errors.Is(err, ErrCounterWrapped) {
+		t.Fatalf("Expected ErrCounterWrapped, got %v", err)
+	}
+
+	errOf := func(_ interface{}, err error) error { return err }
+	cases := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"width", errOf(NewCTRWithNonce(block, nil, 0, CounterLayout{CounterBits: 48})), ErrInvalidCounter},
+		{"start", errOf(NewCTRWithNonce(block, nonce, 1<<32, CTRLayoutRFC3686)), ErrInvalidCounter},
+		{"nonce", errOf(NewCTRWithNonce(block, nonce, 0, CTRLayoutChallenge18)), ErrInvalidNonceSize},
+		{"iv", errOf(NewCTR(block, nonce, CTRLayoutNIST)), ErrInvalidIVSize},
+	}
+	for _, c := range cases {
+		if !errors.Is(c.err, c.want) {
+			t.Errorf("%s: got %v, expected %v", c.name, c.err, c.want)
+		}
+	}
 }
 
 func Test_19(t *testing.T) {
@@ -26,19 +366,136 @@ func Test_19(t *testing.T) {
 }
 
 func Test_20(t *testing.T) {
-	log.Printf("20")
-	BreakCTRStatistically()
+	pts, err := BreakCTRStatistically()
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.Printf("20 output:\n%s", bytes.Join(pts, []byte("\n")))
+}
+
+func Test_20_VariableLength(t *testing.T) {
+	cts, keystream := fixedNonceCiphertexts(t, "input/20.txt")
+	recovered, confidence, pts := BreakFixedNonceCTR(cts, ChiSquaredScorer{})
+	if len(recovered) != len(keystream) || len(confidence) != len(keystream) {
+		t.Fatalf("Recovered %v keystream bytes, expected %v", len(recovered), len(keystream))
+	}
+	for i, ct := range cts {
+		if len(pts[i]) != len(ct) {
+			t.Fatalf("Plaintext %v has length %v, expected %v", i, len(pts[i]), len(ct))
+		}
+	}
+
+	//nearly every column that at least 10 ciphertexts reach should be right
+	wrong, columns := 0, 0
+	for col := range keystream {
+		reach := 0
+		for _, ct := range cts {
+			if col < len(ct) {
+				reach++
+			}
+		}
+		if reach >= 10 {
+			columns++
+			if recovered[col] != keystream[col] {
+				wrong++
+			}
+		}
+	}
+	if float64(wrong) > 0.1*float64(columns) {
+		t.Fatalf("%v of %v well-covered keystream bytes are wrong", wrong, columns)
+	}
+	if confidence[len(confidence)-1] >= confidence[10] {
+		t.Fatal("The last keystream byte should have less confidence than the early ones")
+	}
 }
 
 func Test_21(t *testing.T) {
 	log.Printf("Mersenne Twister's first 3 outputs:")
-	mt := MersenneTwister{}
-	mt.init(100)
+	mt := NewMT19937(100)
 	log.Println(mt.ExtractNumber())
 	log.Println(mt.ExtractNumber())
 	log.Println(mt.ExtractNumber())
 }
 
+// Known answers from mt19937ar.out, the C++ standard's std::mt19937, and
+// Python's random module
+func Test_MT19937(t *testing.T) {
+	check := func(name string, got, expected []uint64) {
+		t.Helper()
+		for i := range expected {
+			if got[i] != expected[i] {
+				t.Fatalf("%s: output %d is %d, expected %d", name, i, got[i], expected[i])
+			}
+		}
+	}
+	outputs := func(f func() uint64, n int) []uint64 {
+		var out []uint64
+		for i := 0; i < n; i++ {
+			out = append(out, f())
+		}
+		return out
+	}
+	uint32s := func(mt *MersenneTwister) func() uint64 {
+		return func() uint64 { return uint64(mt.Uint32()) }
+	}
+
+	mt := NewMT19937(5489)
+	check("init_genrand(5489)", outputs(uint32s(mt), 5), []uint64{3499211612, 581869302, 3890346734, 3586334585, 545404204})
+	if out := outputs(uint32s(mt), 9995); out[9994] != 4123659995 {
+		t.Fatalf("10000th output is %d", out[9994])
+	}
+
+	//the zero value uses the default seed instead of panicking
+	var zero MersenneTwister
+	check("zero value", outputs(uint32s(&zero), 2), []uint64{3499211612, 581869302})
+
+	mt.SeedByArray([]uint32{0x123, 0x234, 0x345, 0x456})
+	check("init_by_array", outputs(uint32s(mt), 5), []uint64{1067595299, 955945823, 477289528, 4107218783, 4228976476})
+
+	//random.seed(5489); random.getrandbits(32)
+	mt.SeedByArray([]uint32{5489})
+	check("python seed", outputs(uint32s(mt), 5), []uint64{3382763572, 956215839, 417760592, 166104981, 4181578304})
+
+	//random.seed(42); random.random()
+	mt.SeedByArray([]uint32{42})
+	for i, expected := range []float64{0.6394267984578837, 0.025010755222666936, 0.27502931836911926} {
+		if f := mt.Float64(); f != expected {
+			t.Fatalf("Float64 %d is %v, expected %v", i, f, expected)
+		}
+	}
+
+	//random.seed(42); random.randrange(1000), then random.randrange(10**12)
+	mt.SeedByArray([]uint32{42})
+	intn := func(n int) func() uint64 {
+		return func() uint64 { return uint64(mt.Intn(n)) }
+	}
+	check("randrange", outputs(intn(1000), 5), []uint64{654, 114, 25, 759, 281})
+	check("randrange", outputs(intn(1000000000000), 3), []uint64{245864938384, 808053162473, 743469555623})
+
+	//random.seed(42); random.getrandbits(64), through math/rand
+	src := NewMT19937Source(42)
+	check("Source64", outputs(src.Uint64, 2), []uint64{2053695854357871005, 13679192365072849617})
+	src.Seed(0x123456789abc)
+	check("Source64 seed", outputs(uint32s(src.MT), 3), []uint64{1611381517, 218704722, 890757533})
+	src.Seed(42)
+	r := rand.New(src)
+	if n := r.Uint64(); n != 2053695854357871005 {
+		t.Fatalf("rand.Rand.Uint64 is %d", n)
+	}
+	if n := r.Int63(); n != int64(13679192365072849617>>1) {
+		t.Fatalf("rand.Rand.Int63 is %d", n)
+	}
+
+	//the zero value is seeded with the default seed on first use
+	var unseeded MT19937Source
+	check("zero Source64", outputs(unseeded.Uint64, 3), outputs(NewMT19937(MT19937DefaultSeed).Uint64, 3))
+	var zeroSeeded MT19937Source
+	zeroSeeded.Seed(42)
+	if n := rand.New(&zeroSeeded).Uint64(); n != 2053695854357871005 {
+		t.Fatalf("Seeded zero value gave %d", n)
+	}
+}
+
 func Test_22(t *testing.T) {
 	//num := WaitThenGenerateNum()
 	//log.Println("num is", num)
@@ -47,8 +504,7 @@ func Test_22(t *testing.T) {
 }
 
 func Test_23(t *testing.T) {
-	mt := MersenneTwister{}
-	mt.init(uint32(GetRandomInt(0x100000000)))
+	mt := NewMT19937(uint32(GetRandomInt(0x100000000)))
 	var numbers []uint32
 	for i := 0; i < 624; i++ {
 		numbers = append(numbers, mt.ExtractNumber())
@@ -65,9 +521,197 @@ func Test_23(t *testing.T) {
 
 }
 
+// Known answers from mt19937-64.out and the C++ standard's std::mt19937_64
+func Test_MT19937_64(t *testing.T) {
+	mt := NewMT19937_64(5489)
+	if n := mt.Uint64(); n != 14514284786278117030 {
+		t.Fatalf("First output is %d", n)
+	}
+	for i := 2; i < 10000; i++ {
+		mt.Uint64()
+	}
+	if n := mt.Uint64(); n != 9981545732273789042 {
+		t.Fatalf("10000th output is %d", n)
+	}
+
+	var zero MersenneTwister64
+	if n := zero.Uint64(); n != 14514284786278117030 {
+		t.Fatalf("Zero value's first output is %d", n)
+	}
+
+	mt.SeedByArray([]uint64{0x12345, 0x23456, 0x34567, 0x45678})
+	for i, expected := range []uint64{7266447313870364031, 4946485549665804864, 16945909448695747420, 16394063075524226720, 4873882236456199058} {
+		if n := mt.Uint64(); n != expected {
+			t.Fatalf("init_by_array64 output %d is %d, expected %d", i, n, expected)
+		}
+	}
+	if f := mt.Float64(); f < 0 || f >= 1 {
+		t.Fatalf("Float64 out of range: %v", f)
+	}
+}
+
+func Test_CloneMT19937_64(t *testing.T) {
+	for i := 0; i < 1000; i++ {
+		y := uint64(GetRandomInt(1<<62))<<2 ^ uint64(i)
+		if UntemperMT64(temperMT64(y)) != y {
+			t.Fatalf("Untemper failed for %x", y)
+		}
+	}
+
+	seed := uint64(GetRandomInt(1 << 62))
+	mt := NewMT19937_64(seed)
+	outputs := make([]uint64, 312)
+	for i := range outputs {
+		outputs[i] = mt.Uint64()
+	}
+	clone, err := CloneMT19937_64(outputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//far enough to cross several twists
+	for i := 0; i < 2000; i++ {
+		if a, b := clone.Uint64(), mt.Uint64(); a != b {
+			t.Fatalf("Clone predicted %d, generator gave %d at %d", a, b, i)
+		}
+	}
+
+	if _, err := CloneMT19937_64(outputs[:311]); !errors.Is(err, ErrInvalidLength) {
+		t.Fatalf("Expected ErrInvalidLength, got %v", err)
+	}
+}
+
 func Test_24(t *testing.T) {
 	BreakMTStreamCipherWithPrefix()
 
 	ct := CreatePasswordResetToken()
 	log.Println("valid token?", CheckPasswordResetToken(ct))
 }
+
+func fixedNonceCiphertexts(t *testing.T, filename string) ([][]byte, []byte) {
+	lines, err := ReadFileByLine(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := Key(16)
+	nonce := make([]byte, 8)
+	var cts [][]byte
+	longest := 0
+	for _, line := range lines {
+		pt, _ := base64.StdEncoding.DecodeString(line)
+		cts = append(cts, CTR_Cipher(pt, key, nonce))
+		if len(pt) > longest {
+			longest = len(pt)
+		}
+	}
+	keystream := CTR_Cipher(make([]byte, longest), key, nonce)
+	return cts, keystream
+}
+
+func Test_19_DragCrib(t *testing.T) {
+	cts, keystream := fixedNonceCiphertexts(t, "input/19.txt")
+	matches := DragCrib(cts, []byte("I have met"), 0, PrintableScorer{}, 0.1)
+	if len(matches) == 0 {
+		t.Fatal("Crib not found")
+	}
+	best := matches[0]
+	if best.Ciphertext != 0 || best.Offset != 0 || !bytes.Equal(best.Keystream, keystream[:10]) {
+		t.Fatalf("Best match is ciphertext %v offset %v", best.Ciphertext, best.Offset)
+	}
+
+	ks := NewPartialKeystream(0)
+	if conflicts := ks.AddCrib(best); len(conflicts) != 0 {
+		t.Fatalf("Unexpected conflicts %v", conflicts)
+	}
+	pt, known := ks.Decrypt(cts[1])
+	if string(pt[:10]) != "Coming wit" || !known[9] || known[10] {
+		t.Fatalf("Partial decryption %q %v", pt, known)
+	}
+	if ks.Provenance[3].Ciphertext != 0 || string(ks.Provenance[3].Crib) != "I have met" {
+		t.Fatalf("Wrong provenance %+v", ks.Provenance[3])
+	}
+
+	//a wrong guess for a known byte is reported as a conflict
+	wrong := best
+	wrong.Keystream = XOr(cts[0][:10], []byte("I have mat"))
+	if conflicts := ks.AddCrib(wrong); len(conflicts) != 1 || conflicts[0] != 8 {
+		t.Fatalf("Expected a conflict at offset 8, got %v", conflicts)
+	}
+}
+
+func Test_RepeatingKeyXOrCrib(t *testing.T) {
+	pt := []byte("Burning 'em, if you ain't quick and nimble\nI go crazy when I hear a cymbal")
+	ct := RepeatingKeyXOrCipher(pt, []byte("ICE"))
+	matches := DragCrib([][]byte{ct}, []byte("quick"), 3, PrintableScorer{}, 0.1)
+	found := false
+	for _, m := range matches {
+		if m.Offset == bytes.Index(pt, []byte("quick")) {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("Crib not found among %v matches", len(matches))
+	}
+
+	ks := NewPartialKeystream(3)
+	ks.AddCrib(matches[0])
+	out, _ := ks.Decrypt(ct)
+	if !bytes.Equal(out, pt) || ks.Coverage(3) != 1 {
+		t.Fatalf("Decrypted %q", out)
+	}
+}
+
+func Test_MT19937StreamCipherCrib(t *testing.T) {
+	key := uint16(GetRandomInt(0x10000))
+	plaintexts := []string{
+		"Meet me at the old bridge at midnight",
+		"Bring the documents and come alone",
+		"The password for the vault is changed",
+	}
+	var cts [][]byte
+	for _, p := range plaintexts {
+		cts = append(cts, MT19937StreamCipher([]byte(p), key))
+	}
+	matches := DragCrib(cts, []byte("Bring the "), 0, PrintableScorer{}, 0.1)
+	if len(matches) == 0 || matches[0].Ciphertext != 1 || matches[0].Offset != 0 {
+		t.Fatalf("Crib not found: %+v", matches)
+	}
+	if string(matches[0].Fragments[0]) != "Meet me at" {
+		t.Fatalf("Unexpected fragment %q", matches[0].Fragments[0])
+	}
+}
+
+func Test_19_FixedNonceSession(t *testing.T) {
+	cts, keystream := fixedNonceCiphertexts(t, "input/19.txt")
+	session := NewFixedNonceSession(cts)
+	if _, err := session.Guess(0, 0, []byte("I have met them")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := session.Guess(0, 30, []byte("too long")); err == nil {
+		t.Fatal("Expected an error for a guess past the end of the row")
+	}
+
+	session.AutoFill(ChiSquaredScorer{})
+	if !bytes.Equal(session.Keystream.Bytes[:15], keystream[:15]) {
+		t.Fatal("AutoFill overwrote guessed columns")
+	}
+	if session.Keystream.Provenance[20].Ciphertext != -1 {
+		t.Fatalf("Unexpected provenance %+v", session.Keystream.Provenance[20])
+	}
+
+	filename := filepath.Join(t.TempDir(), "session.json")
+	if err := session.Save(filename); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadFixedNonceSession(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pts, _ := session.Plaintexts()
+	loadedPts, _ := loaded.Plaintexts()
+	for i := range pts {
+		if !bytes.Equal(pts[i], loadedPts[i]) {
+			t.Fatalf("Row %v differs after loading: %q vs %q", i, pts[i], loadedPts[i])
+		}
+	}
+	log.Printf("19 after one guess and AutoFill:\n%s", bytes.Join(pts, []byte("\n")))
+}
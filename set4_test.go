@@ -1,8 +1,15 @@
 package cryptopals
 
 import (
+	"bytes"
 	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
 	"log"
+	"os"
+	"path/filepath"
+	"sync"
 	"testing"
 )
 
@@ -21,16 +28,318 @@ func Test_25(t *testing.T) {
 	pt := AESInECBModeDecrypt(ct, []byte("YELLOW SUBMARINE"))
 
 	ct, updateFunc := CTRRandomAccessReadWrite(pt)
-	pt = BreakCTRRandomAccess(ct, updateFunc)
-	log.Println(string(pt))
+	recovered := BreakCTRRandomAccess(ct, updateFunc)
+	if !bytes.Equal(recovered, pt) {
+		t.Fatal("Plaintext not recovered")
+	}
+	log.Println(string(recovered))
+}
+
+func Test_BreakCTREditOracle(t *testing.T) {
+	pt := Key(4 << 20)
+	ct, edit := CTRRandomAccessReadWrite(pt)
+
+	recovered, queries, err := BreakCTREditOracle(ct, edit)
+	if err != nil || queries != 1 || !bytes.Equal(recovered, pt) {
+		t.Fatalf("Recovered %v after %d edits (%v)", bytes.Equal(recovered, pt), queries, err)
+	}
+
+	//an oracle that only edits up to 1000 bytes at a time
+	limited := func(ct []byte, offset int, newtext []byte) []byte {
+		if len(newtext) > 1000 {
+			return ct
+		}
+		return edit(ct, offset, newtext)
+	}
+	pt = pt[:100000]
+	ct, edit = CTRRandomAccessReadWrite(pt)
+	recovered, queries, err = BreakCTREditOracleChunked(ct, limited, 1000)
+	if err != nil || queries != 100 || !bytes.Equal(recovered, pt) {
+		t.Fatalf("Recovered %v after %d edits (%v)", bytes.Equal(recovered, pt), queries, err)
+	}
+
+	//asking it for more than it allows leaves the ciphertext as it was
+	if _, _, err := BreakCTREditOracleChunked(ct, limited, 2000); !errors.Is(err, ErrUnexpectedOracle) {
+		t.Fatalf("Expected ErrUnexpectedOracle for an ignored edit, got %v", err)
+	}
+
+	//plaintext that's already zeros isn't mistaken for an ignored edit
+	zeroed := append(append(Key(1000), make([]byte, 1000)...), Key(500)...)
+	ct, edit = CTRRandomAccessReadWrite(zeroed)
+	recovered, queries, err = BreakCTREditOracleChunked(ct, limited, 1000)
+	if err != nil || queries != 4 || !bytes.Equal(recovered, zeroed) {
+		t.Fatalf("Recovered %v after %d edits (%v)", bytes.Equal(recovered, zeroed), queries, err)
+	}
+
+	if _, _, err := BreakCTREditOracleChunked(ct, limited, 0); !errors.Is(err, ErrInvalidLength) {
+		t.Fatalf("Expected ErrInvalidLength, got %v", err)
+	}
+	truncating := func(ct []byte, offset int, newtext []byte) []byte { return ct[:offset] }
+	if _, _, err := BreakCTREditOracle(ct, truncating); !errors.Is(err, ErrUnexpectedOracle) {
+		t.Fatalf("Expected ErrUnexpectedOracle, got %v", err)
+	}
+}
+
+func Test_CTRRandomAccessEdit(t *testing.T) {
+	pt := []byte("YELLOW SUBMARINE, and a bit more")
+	ct, edit := CTRRandomAccessReadWrite(pt)
+	original := append([]byte{}, ct...)
+
+	//an edit past the end extends the ciphertext instead of panicking
+	edited := edit(ct, len(ct)+4, []byte("tail"))
+	if !bytes.Equal(ct, original) {
+		t.Fatal("Edit changed the ciphertext passed in")
+	}
+	if len(edited) != len(ct)+8 || !bytes.Equal(edited[:len(ct)], ct) {
+		t.Fatalf("Edit past the end gave %d bytes", len(edited))
+	}
+}
+
+// encryptedFileModel checks an EncryptedFile against the plaintext it should hold.
+func encryptedFileModel(t *testing.T, f *EncryptedFile, expected []byte) {
+	t.Helper()
+	if f.Size() != int64(len(expected)) {
+		t.Fatalf("Size %d, expected %d", f.Size(), len(expected))
+	}
+	got := make([]byte, len(expected)+10)
+	n, err := f.ReadAt(got, 0)
+	if err != io.EOF || n != len(expected) || !bytes.Equal(got[:n], expected) {
+		t.Fatalf("Read %d bytes (%v), expected %d", n, err, len(expected))
+	}
+}
+
+func Test_EncryptedFile(t *testing.T) {
+	for _, authenticated := range []bool{false, true} {
+		key, nonce, macKey := Key(16), Key(8), Key(32)
+		name := filepath.Join(t.TempDir(), "file")
+		open := func() (*os.File, *EncryptedFile) {
+			disk, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE, 0600)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var f *EncryptedFile
+			if authenticated {
+				f, err = OpenAuthenticatedEncryptedFile(disk, key, macKey)
+			} else {
+				f, err = OpenEncryptedFile(disk, key, nonce)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			return disk, f
+		}
+
+		disk, f := open()
+		var model []byte
+		write := func(p []byte, off int) {
+			if _, err := f.WriteAt(p, int64(off)); err != nil {
+				t.Fatal(err)
+			}
+			if end := off + len(p); end > len(model) {
+				model = append(model, make([]byte, end-len(model))...)
+			}
+			copy(model[off:], p)
+		}
+		write(bytes.Repeat([]byte("0123456789"), 1000), 0)
+		write([]byte("across a block boundary"), EncryptedFileBlockSize-5)
+		write([]byte("after a gap"), 3*EncryptedFileBlockSize+100)
+		write([]byte("short"), 7)
+		encryptedFileModel(t, f, model)
+
+		//Seek, Read and Write
+		if _, err := f.Seek(-11, io.SeekEnd); err != nil {
+			t.Fatal(err)
+		}
+		buf := make([]byte, 5)
+		if _, err := f.Read(buf); err != nil || string(buf) != "after" {
+			t.Fatalf("Read %q (%v)", buf, err)
+		}
+		if _, err := f.Write([]byte("-")); err != nil {
+			t.Fatal(err)
+		}
+		model[len(model)-6] = '-'
+		if _, err := f.Seek(-1, io.SeekStart); !errors.Is(err, ErrInvalidOffset) {
+			t.Fatalf("Expected ErrInvalidOffset, got %v", err)
+		}
+		disk.Close()
+
+		disk, f = open()
+		encryptedFileModel(t, f, model)
+
+		//unauthenticated storage is the CTR ciphertext itself
+		raw, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !authenticated && !bytes.Equal(raw, CTR_Cipher(model, key, nonce)) {
+			t.Fatal("Storage isn't the CTR ciphertext")
+		}
+
+		//tampering is only caught with MACs
+		if _, err := disk.WriteAt([]byte{raw[100] ^ 1}, 100); err != nil {
+			t.Fatal(err)
+		}
+		_, err = f.ReadAt(make([]byte, 10), 0)
+		if authenticated != errors.Is(err, ErrAuthenticationFailed) {
+			t.Fatalf("Authenticated %v, tampered read returned %v", authenticated, err)
+		}
+		disk.Close()
+	}
+}
+
+// failingStorage is memoryStorage that only lets through its next writes
+// writes, and fails the rest.
+type failingStorage struct {
+	memoryStorage
+	writes int
+}
+
+func (s *failingStorage) WriteAt(p []byte, off int64) (int, error) {
+	if s.writes == 0 {
+		return 0, errors.New("disk full")
+	}
+	s.writes--
+	return s.memoryStorage.WriteAt(p, off)
+}
+
+func Test_EncryptedFileConcurrency(t *testing.T) {
+	for _, authenticated := range []bool{false, true} {
+		storage := &memoryStorage{}
+		var f *EncryptedFile
+		var err error
+		if authenticated {
+			f, err = NewAuthenticatedEncryptedFile(storage, 0, Key(16), Key(32))
+		} else {
+			f, err = NewEncryptedFile(storage, 0, Key(16), Key(8))
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		//writes to different parts of the same blocks, with reads going on
+		//at the same time, none of them lost
+		const writers, chunk = 16, 100
+		var wg sync.WaitGroup
+		for i := 0; i < writers; i++ {
+			wg.Add(2)
+			go func(i int) {
+				defer wg.Done()
+				if _, err := f.WriteAt(bytes.Repeat([]byte{byte('a' + i)}, chunk), int64(i*chunk)); err != nil {
+					t.Error(err)
+				}
+			}(i)
+			go func() {
+				defer wg.Done()
+				if _, err := f.ReadAt(make([]byte, chunk), 0); err != nil && err != io.EOF {
+					t.Error(err)
+				}
+			}()
+		}
+		wg.Wait()
+		var expected []byte
+		for i := 0; i < writers; i++ {
+			expected = append(expected, bytes.Repeat([]byte{byte('a' + i)}, chunk)...)
+		}
+		encryptedFileModel(t, f, expected)
+	}
+
+	//a write over three blocks that fails on the third has written two
+	storage := &failingStorage{}
+	storage.writes = 2
+	f, err := NewAuthenticatedEncryptedFile(storage, 0, Key(16), Key(32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := bytes.Repeat([]byte("x"), 3*EncryptedFileBlockSize-10)
+	n, err := f.WriteAt(p, 10)
+	if err == nil || n != 2*EncryptedFileBlockSize-10 || f.Size() != 2*EncryptedFileBlockSize {
+		t.Fatalf("Wrote %d bytes of a failed write, size %d (%v)", n, f.Size(), err)
+	}
+	encryptedFileModel(t, f, append(make([]byte, 10), p[:n]...))
+}
+
+func Test_EncryptedFileEditOracle(t *testing.T) {
+	secret := bytes.Repeat([]byte("attack at dawn! "), 600)
+	for _, authenticated := range []bool{false, true} {
+		storage := &memoryStorage{}
+		var f *EncryptedFile
+		var err error
+		if authenticated {
+			f, err = NewAuthenticatedEncryptedFile(storage, 0, Key(16), Key(32))
+		} else {
+			f, err = NewEncryptedFile(storage, 0, Key(16), Key(8))
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.WriteAt(secret, 0); err != nil {
+			t.Fatal(err)
+		}
+
+		//the attacker sees the storage and can overwrite the contents, but
+		//not read them
+		before := append([]byte{}, storage.data...)
+		if _, err := f.WriteAt(make([]byte, len(secret)), 0); err != nil {
+			t.Fatal(err)
+		}
+		recovered := XOr(before, storage.data)
+		if authenticated == bytes.Contains(recovered, secret) {
+			t.Fatalf("Authenticated %v, but plaintext recovered: %v", authenticated, !authenticated)
+		}
+	}
 }
 
 func Test_26(t *testing.T) {
-	log.Println(createBitflippedAdminCTR())
+	if !createBitflippedAdminCTR() {
+		t.Fatal("Admin account creation not successful")
+	}
 }
 
 func Test_27(t *testing.T) {
-	log.Println("out", findCBCKey())
+	key := findCBCKey()
+	if len(key) != 16 {
+		t.Fatal("Key recovery not successful")
+	}
+	log.Println("out", key)
+}
+
+func Test_RecoverKeyUsedAsIV(t *testing.T) {
+	key := Key(16)
+	encrypt := func(input []byte) []byte {
+		return AESInCBCModeEncrypt(Pad(input, 16), key, key)
+	}
+	//a receiver that wraps the rejection in an error of its own
+	decrypt := func(ct []byte) error {
+		pt := AESInCBCModeDecrypt(ct, key, key)
+		for _, b := range pt {
+			if b >= 0x80 {
+				return fmt.Errorf("request rejected: %w", &NonASCIIError{pt})
+			}
+		}
+		return nil
+	}
+	recovered, err := RecoverKeyUsedAsIV(encrypt, decrypt)
+	if err != nil || !bytes.Equal(recovered, key) {
+		t.Fatalf("Recovered %x (%v), expected %x", recovered, err, key)
+	}
+
+	//a receiver that doesn't leak anything
+	silent := func(ct []byte) error { return errors.New("bad request") }
+	if _, err := RecoverKeyUsedAsIV(encrypt, silent); !errors.Is(err, ErrKeyRecoveryFailed) {
+		t.Fatalf("Expected ErrKeyRecoveryFailed, got %v", err)
+	}
+
+	//a service whose IV isn't the key
+	iv := Key(16)
+	separate := func(input []byte) []byte {
+		return AESInCBCModeEncrypt(Pad(input, 16), key, iv)
+	}
+	leaky := func(ct []byte) error {
+		return &NonASCIIError{AESInCBCModeDecrypt(ct, key, iv)}
+	}
+	if _, err := RecoverKeyUsedAsIV(separate, leaky); !errors.Is(err, ErrKeyRecoveryFailed) {
+		t.Fatalf("Expected ErrKeyRecoveryFailed, got %v", err)
+	}
 }
 
 func Test_28(t *testing.T) {
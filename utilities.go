@@ -3,6 +3,7 @@ package cryptopals
 import (
 	"bufio"
 	"crypto/rand"
+	"fmt"
 	"math/big"
 	"os"
 )
@@ -28,14 +29,27 @@ func ReadFileByLine(filename string) ([]string, error) {
 }
 
 func BreakIntoBlocks(buffer []byte, size int) [][]byte {
+	out, err := BreakIntoBlocksChecked(buffer, size)
+	if err != nil {
+		panic(err)
+	}
+	return out
+}
+
+// BreakIntoBlocksChecked is BreakIntoBlocks, but returns an error instead
+// of panicking when size isn't positive or doesn't divide the buffer.
+func BreakIntoBlocksChecked(buffer []byte, size int) ([][]byte, error) {
+	if size < 1 {
+		return nil, ErrInvalidBlockSize
+	}
 	if len(buffer)%size != 0 {
-		panic("Buffer isn't a multiple of size")
+		return nil, fmt.Errorf("%w: %d bytes, block size %d", ErrNotBlockAligned, len(buffer), size)
 	}
 	var out [][]byte
 	for i := 0; i < len(buffer); i += size {
 		out = append(out, buffer[i:i+size])
 	}
-	return out
+	return out, nil
 }
 
 func Flatten(blocks [][]byte) []byte {
@@ -55,13 +69,22 @@ func GetRandomInt(max int) int {
 }
 
 func Key(length int) []byte {
+	buf, err := KeyChecked(length)
+	if err != nil {
+		panic(err)
+	}
+	return buf
+}
+
+// KeyChecked is Key, but returns an error instead of panicking.
+func KeyChecked(length int) ([]byte, error) {
 	if length < 1 {
-		panic("Length needs to be a positive number")
+		return nil, ErrInvalidLength
 	}
 	buf := make([]byte, length)
 	_, err := rand.Read(buf)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	return buf
+	return buf, nil
 }